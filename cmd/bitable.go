@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -76,6 +77,56 @@ func exportBitable(ctx context.Context, client *core.Client, url string, format
 		}
 	}
 
+	headers, rows, ordered, err := loadBitableTable(ctx, client, appToken, tableID, viewID, strings.EqualFold(format, "csv"), viewFieldsOnly, filterImages)
+	if err != nil {
+		return "", err
+	}
+
+	// 构建文件名:根据preferName决定使用自定义名称还是原始标题
+	var baseName string
+	if preferName != "" {
+		// 使用配置中的自定义名称
+		baseName = sanitizeFileName(preferName)
+	} else {
+		// 使用原始标题: App_Table_View (对齐Web导出)
+		parts := []string{sanitizeFileName(appName), sanitizeFileName(tableName)}
+		if viewName != "" {
+			parts = append(parts, sanitizeFileName(viewName))
+		}
+		baseName = strings.Join(parts, "_")
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", err
+	}
+
+	var actualFileName string
+	switch strings.ToLower(format) {
+	case "csv":
+		actualFileName = baseName + ".csv"
+		out := filepath.Join(outputDir, actualFileName)
+		if err := writeCSV(out, headers, rows); err != nil {
+			return "", err
+		}
+		fmt.Printf("Exported CSV to %s\n", out)
+	case "xlsx":
+		actualFileName = baseName + ".xlsx"
+		out := filepath.Join(outputDir, actualFileName)
+		if err := writeXLSX(out, headers, rows, ordered); err != nil {
+			return "", err
+		}
+		fmt.Printf("Exported XLSX to %s\n", out)
+	default:
+		return "", fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	return actualFileName, nil
+}
+
+// loadBitableTable 拉取多维表格的字段与记录,并按 formatFieldValue 规则格式化
+// 返回表头、数据行以及按视图顺序排列的字段信息,供文件导出与文档内嵌共用
+// isCSV 为 true 时多行文本等字段按 CSV 的习惯格式化
+func loadBitableTable(ctx context.Context, client *core.Client, appToken, tableID, viewID string, isCSV bool, viewFieldsOnly bool, filterImages bool) ([]string, [][]string, []fieldInfo, error) {
 	// 按视图顺序准备字段列表
 	var viewPtr *string
 	if viewID != "" {
@@ -83,10 +134,10 @@ func exportBitable(ctx context.Context, client *core.Client, url string, format
 	}
 	fields, err := client.GetBitableFieldList(ctx, appToken, tableID, viewPtr)
 	if err != nil {
-		return "", fmt.Errorf("get fields failed: %w", err)
+		return nil, nil, nil, fmt.Errorf("get fields failed: %w", err)
 	}
 	if len(fields) == 0 {
-		return "", fmt.Errorf("no fields returned for table %s", tableID)
+		return nil, nil, nil, fmt.Errorf("no fields returned for table %s", tableID)
 	}
 
 	// 构建字段信息映射(用于选项字段的名称映射)
@@ -121,7 +172,7 @@ func exportBitable(ctx context.Context, client *core.Client, url string, format
 	for {
 		resp, err := client.GetBitableRecordPage(ctx, appToken, tableID, viewPtr, pageToken, pageSize)
 		if err != nil {
-			return "", fmt.Errorf("list records failed: %w", err)
+			return nil, nil, nil, fmt.Errorf("list records failed: %w", err)
 		}
 
 		// 根据视图实际可见字段缩小列范围(基于记录中的实际字段键)
@@ -148,7 +199,6 @@ func exportBitable(ctx context.Context, client *core.Client, url string, format
 
 		for _, item := range resp.Items {
 			row := make([]string, 0, len(ordered))
-			isCSV := strings.EqualFold(format, "csv")
 			for _, col := range ordered {
 				val := extractField(item.Fields, col.id, col.name)
 				row = append(row, formatFieldValue(col, val, isCSV, filterImages))
@@ -167,45 +217,98 @@ func exportBitable(ctx context.Context, client *core.Client, url string, format
 		headers = append(headers, col.name)
 	}
 
-	// 构建文件名:根据preferName决定使用自定义名称还是原始标题
-	var baseName string
-	if preferName != "" {
-		// 使用配置中的自定义名称
-		baseName = sanitizeFileName(preferName)
-	} else {
-		// 使用原始标题: App_Table_View (对齐Web导出)
-		parts := []string{sanitizeFileName(appName), sanitizeFileName(tableName)}
-		if viewName != "" {
-			parts = append(parts, sanitizeFileName(viewName))
+	return headers, rows, ordered, nil
+}
+
+// loadEmbeddedBitables 拉取文档中内嵌多维表格块的数据,供解析器渲染为表格
+// 每个表格的完整数据另存为 CSV 到 assetDir,链接使用相对于文档的 linkDir
+// 单个表格拉取失败只向 log 打印警告,解析器会为其输出占位注释
+func loadEmbeddedBitables(ctx context.Context, client *core.Client, blocks []*lark.DocxBlock, assetDir, linkDir string, filterImages bool, log io.Writer) map[string]*core.BitableTable {
+	tables := make(map[string]*core.BitableTable)
+	// 同一个多维表格的数据表列表只拉取一次
+	tableLists := make(map[string][]*lark.GetBitableTableListRespItem)
+	csvNames := make(map[string]bool)
+	for _, b := range blocks {
+		if b.BlockType != lark.DocxBlockTypeBitable || b.Bitable == nil || b.Bitable.Token == "" {
+			continue
+		}
+		token := b.Bitable.Token
+		if _, ok := tables[token]; ok {
+			continue
+		}
+		// 内嵌块的 token 形如 bascnXXX_tblYYY,部分文档还带有视图 bascnXXX_tblYYY_vewZZZ
+		parts := strings.Split(token, "_")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			fmt.Fprintf(log, "  ⚠️  无法识别的多维表格 token: %s\n", token)
+			continue
+		}
+		appToken, tableID := parts[0], parts[1]
+		viewID := ""
+		if len(parts) > 2 {
+			viewID = parts[2]
+		} else {
+			viewID = embeddedBitableView(ctx, client, appToken, tableID, b.Bitable.ViewType)
 		}
-		baseName = strings.Join(parts, "_")
-	}
 
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", err
-	}
+		headers, rows, _, err := loadBitableTable(ctx, client, appToken, tableID, viewID, true, viewID != "", filterImages)
+		if err != nil {
+			fmt.Fprintf(log, "  ⚠️  内嵌多维表格 %s 拉取失败: %v\n", token, err)
+			continue
+		}
 
-	var actualFileName string
-	switch strings.ToLower(format) {
-	case "csv":
-		actualFileName = baseName + ".csv"
-		out := filepath.Join(outputDir, actualFileName)
-		if err := writeCSV(out, headers, rows); err != nil {
-			return "", err
+		list, ok := tableLists[appToken]
+		if !ok {
+			list, _ = client.GetBitableTableList(ctx, appToken)
+			tableLists[appToken] = list
 		}
-		fmt.Printf("Exported CSV to %s\n", out)
-	case "xlsx":
-		actualFileName = baseName + ".xlsx"
-		out := filepath.Join(outputDir, actualFileName)
-		if err := writeXLSX(out, headers, rows, ordered); err != nil {
-			return "", err
+		tableName := tableID
+		for _, t := range list {
+			if t.TableID == tableID && t.Name != "" {
+				tableName = t.Name
+				break
+			}
 		}
-		fmt.Printf("Exported XLSX to %s\n", out)
-	default:
-		return "", fmt.Errorf("unsupported export format: %s", format)
+
+		// 默认名称的数据表(如"数据表")在同一文档中很常见,重名时追加 table id
+		csvName := sanitizeFileName(tableName) + ".csv"
+		if csvNames[csvName] {
+			csvName = sanitizeFileName(tableName) + "_" + tableID + ".csv"
+		}
+		csvNames[csvName] = true
+
+		table := &core.BitableTable{Headers: headers, Rows: rows}
+		if assetDir == "" {
+			// 不落盘时仍给出 CSV 链接，与已导出的文档保持一致
+			table.CSVLink = filepath.ToSlash(filepath.Join(linkDir, csvName))
+		} else if err := os.MkdirAll(assetDir, 0755); err == nil {
+			if err := writeCSV(filepath.Join(assetDir, csvName), headers, rows); err == nil {
+				table.CSVLink = filepath.ToSlash(filepath.Join(linkDir, csvName))
+			} else {
+				fmt.Fprintf(log, "  ⚠️  内嵌多维表格 %s 写入 CSV 失败: %v\n", token, err)
+			}
+		}
+		tables[token] = table
 	}
+	return tables
+}
 
-	return actualFileName, nil
+// embeddedBitableView 在 token 不带视图时,按内嵌块的视图类型选取数据表中第一个对应的视图
+// 找不到时返回空字符串,即不按视图过滤
+func embeddedBitableView(ctx context.Context, client *core.Client, appToken, tableID string, viewType lark.DocxBitableViewType) string {
+	want := "grid"
+	if viewType == lark.DocxBitableViewTypeKanban {
+		want = "kanban"
+	}
+	views, err := client.GetBitableViewList(ctx, appToken, tableID)
+	if err != nil {
+		return ""
+	}
+	for _, v := range views {
+		if v.ViewType == want {
+			return v.ViewID
+		}
+	}
+	return ""
 }
 
 // resolveBitableAppToken 尝试从给定 URL 获取多维表格的 app token (bascn...)
@@ -882,7 +985,7 @@ func excelColumnName(n int) string {
 	name := ""
 	for n > 0 {
 		n--
-		name = string('A'+(n%26)) + name
+		name = string(rune('A'+(n%26))) + name
 		n /= 26
 	}
 	return name
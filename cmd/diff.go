@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/urfave/cli/v2"
+)
+
+type DiffOpts struct {
+	configPath string
+	group      string
+	word       bool
+	stat       bool
+}
+
+var diffOpts = DiffOpts{}
+
+// getDiffCommand returns the diff command definition
+func getDiffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "Show changes between the synced markdown files and the current remote documents",
+		ArgsUsage: "<url or name>",
+		Description: "Exits with 0 when the local files are up to date, 1 when a document changed " +
+			"and 2 on errors. Without argument, --stat summarizes all configured documents.",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:        "word",
+				Usage:       "Show a word-level diff instead of a unified diff",
+				Destination: &diffOpts.word,
+			},
+			&cli.BoolFlag{
+				Name:        "stat",
+				Usage:       "Only show the number of changed lines per document",
+				Destination: &diffOpts.stat,
+			},
+			&cli.StringFlag{
+				Name:        "group",
+				Usage:       "Diff only documents of a specific group",
+				Destination: &diffOpts.group,
+			},
+			&cli.StringFlag{
+				Name:        "config",
+				Usage:       "Path to config file",
+				Destination: &diffOpts.configPath,
+			},
+		},
+		Action: handleDiffCommand,
+	}
+}
+
+func handleDiffCommand(ctx *cli.Context) error {
+	syncConfig, err := LoadSyncConfig(diffOpts.configPath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to load sync config: %v", err), 2)
+	}
+	configPath, err := core.GetConfigFilePath()
+	if err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	feishuConfig, err := core.ReadConfigFromFile(configPath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to load feishu config: %v", err), 2)
+	}
+	if syncConfig.Sync.AssetsLayout != "" {
+		feishuConfig.Output.AssetsLayout = syncConfig.Sync.AssetsLayout
+	}
+	if err := feishuConfig.Output.Validate(); err != nil {
+		return cli.Exit(err.Error(), 2)
+	}
+	dlConfig = *feishuConfig
+
+	var documents []DocConfig
+	if ctx.NArg() > 0 {
+		doc, err := findSyncDocument(syncConfig, ctx.Args().First())
+		if err != nil {
+			return cli.Exit(err.Error(), 2)
+		}
+		documents = []DocConfig{doc}
+	} else if diffOpts.stat {
+		documents = syncConfig.GetDocuments(diffOpts.group)
+	} else {
+		return cli.Exit("Please specify the document url or name, or use --stat", 2)
+	}
+
+	client := core.NewClient(feishuConfig.Feishu.AppId, feishuConfig.Feishu.AppSecret)
+	changed, failed := 0, 0
+	total := core.DiffStat{}
+	for _, doc := range documents {
+		docType := syncDocType(doc)
+		if docType != "docx" && docType != "wiki" && docType != "wiki_page" {
+			if ctx.NArg() > 0 {
+				return cli.Exit(fmt.Sprintf("文档 %s 的类型 %s 不支持 diff", doc.Name, docType), 2)
+			}
+			continue
+		}
+		stat, err := diffDocument(context.Background(), client, doc, docType, &syncConfig.Sync)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", doc.Name, err)
+			failed++
+			continue
+		}
+		if stat.Changed() {
+			changed++
+		}
+		total.Added += stat.Added
+		total.Removed += stat.Removed
+	}
+	if diffOpts.stat && len(documents) > 1 {
+		fmt.Printf(" %d 个文档有变化，+%d -%d\n", changed, total.Added, total.Removed)
+	}
+
+	if failed > 0 {
+		return cli.Exit("", 2)
+	}
+	if changed > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// findSyncDocument 按名称或 URL 查找同步配置中的文档
+func findSyncDocument(config *SyncConfig, nameOrURL string) (DocConfig, error) {
+	for _, doc := range config.Documents {
+		if doc.Name == nameOrURL || doc.URL == nameOrURL {
+			return doc, nil
+		}
+	}
+	return DocConfig{}, fmt.Errorf("document %s not found in sync configuration", nameOrURL)
+}
+
+// diffDocument 在内存中按同步配置重新渲染远程文档，并与同步状态中记录的本地文件比较
+func diffDocument(ctx context.Context, client *core.Client, doc DocConfig, docType string, syncSettings *SyncSettings) (core.DiffStat, error) {
+	outputDir := syncSettings.OutputDir
+	if syncSettings.OrganizeByGroup && doc.Group != "" {
+		outputDir = filepath.Join(outputDir, doc.Group)
+	}
+	localPath := syncedFilePath(doc, outputDir)
+	local, err := os.ReadFile(localPath)
+	if err != nil {
+		return core.DiffStat{}, err
+	}
+
+	opts := newSyncDownloadOpts(doc, docType, filepath.Dir(localPath), syncSettings)
+	opts.dryRun = true
+	// 渲染过程的进度信息输出到 stderr，保持 stdout 只有 diff 内容
+	opts.log = os.Stderr
+	remote, err := renderDocument(ctx, client, doc.URL, &opts)
+	if err != nil {
+		return core.DiffStat{}, err
+	}
+	markdown := linkLocalMedia(remote.markdown, string(local), remote.mediaTokens)
+
+	var diff string
+	var stat core.DiffStat
+	if diffOpts.word {
+		diff, stat = core.WordDiff(string(local), markdown)
+	} else {
+		diff, stat = core.UnifiedDiff(string(local), markdown, localPath, doc.URL)
+	}
+	if diffOpts.stat {
+		fmt.Printf(" %s | +%d -%d\n", localPath, stat.Added, stat.Removed)
+	} else {
+		fmt.Print(diff)
+	}
+	return stat, nil
+}
+
+// syncedFilePath 返回同步状态（.feishu2md 下的元数据）中记录的本地文件，
+// 没有记录时按文档名推断
+func syncedFilePath(doc DocConfig, outputDir string) string {
+	metaName := fmt.Sprintf("%s.meta", utils.SanitizeFileName(doc.Name))
+	// 按分组存放时 saveDocumentMetadataWithFileName 会把元数据再嵌套到一层分组目录下
+	for _, metaDir := range []string{
+		filepath.Join(outputDir, ".feishu2md"),
+		filepath.Join(outputDir, doc.Group, ".feishu2md"),
+	} {
+		data, err := os.ReadFile(filepath.Join(metaDir, metaName))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "ActualFileName=") {
+				return filepath.Join(outputDir, strings.TrimPrefix(line, "ActualFileName="))
+			}
+		}
+	}
+	return filepath.Join(outputDir, fmt.Sprintf("%s.md", utils.SanitizeFileName(doc.Name)))
+}
+
+// linkLocalMedia 将远程渲染结果中未下载的图片 token 替换为本地文件中引用同一 token 的链接，
+// 避免图片路径被误报为变化；共享图片目录按内容哈希命名，无法对应时保留 token
+func linkLocalMedia(remote, local string, tokens []string) string {
+	if len(tokens) == 0 {
+		return remote
+	}
+	quoted := make([]string, len(tokens))
+	for i, token := range tokens {
+		quoted[i] = regexp.QuoteMeta(token)
+	}
+	// 一次扫描本地文件，每个 token 取第一个引用它的链接
+	re := regexp.MustCompile(`[^\s()<>"']*(` + strings.Join(quoted, "|") + `)[^\s()<>"']*`)
+	links := make(map[string]string)
+	for _, m := range re.FindAllStringSubmatch(local, -1) {
+		if _, ok := links[m[1]]; !ok {
+			links[m[1]] = m[0]
+		}
+	}
+	for token, link := range links {
+		remote = strings.ReplaceAll(remote, token, link)
+	}
+	return remote
+}
@@ -3,8 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -20,23 +25,322 @@ type DownloadOpts struct {
 	dump             bool
 	batch            bool
 	wiki             bool
-	docName          string // Optional custom document name
-	skipImages       bool   // 是否跳过图片下载
-	useOriginalTitle bool   // Whether to use original title instead of docName
+	docName          string                 // Optional custom document name
+	skipImages       bool                   // 是否跳过图片下载
+	useOriginalTitle bool                   // Whether to use original title instead of docName
+	bitableMaxRows   *int                   // 内嵌多维表格最多渲染的行数，0 表示不限制，nil 表示使用配置文件中的设置
+	assetsRoot       string                 // 共享图片目录所在的根目录，为空时使用 outputDir
+	group            string                 // 同步配置中的分组，写入 front matter
+	frontMatter      map[string]interface{} // 同步配置中为单个文档追加的 front matter 静态字段
+	comments         string                 // 评论导出方式：footnotes 或 appendix，覆盖配置文件
+	commentsJSON     bool                   // 将评论另存为 <文档名>.comments.json
+	revision         int64                  // 下载指定的历史版本，0 表示最新版本
+	history          bool                   // 导出文档的全部历史版本和变更日志
+	historyLimit     int                    // 只导出最近的若干个历史版本，0 表示全部
+	textOnly         bool                   // 只渲染正文：不拉取评论和内嵌多维表格，图片引用飞书上的地址
+	dryRun           bool                   // 只在内存中渲染，不下载图片、不写入任何文件
+	include          []string               // 知识库下载时只下载标题或路径匹配的节点及其子树
+	exclude          []string               // 知识库下载时跳过标题或路径匹配的节点及其子树
+	maxDepth         int                    // 知识库下载时最多下载的层级，0 表示不限制
+	site             string                 // 知识库下载时生成的导航：mkdocs、docusaurus、mdbook 或 prefix
+	log              io.Writer              // 渲染过程的进度信息输出位置，为空时输出到 stdout
+}
+
+// logWriter 返回进度信息的输出位置
+func (o *DownloadOpts) logWriter() io.Writer {
+	if o.log == nil {
+		return os.Stdout
+	}
+	return o.log
+}
+
+// logf 输出渲染过程的进度信息
+func (o *DownloadOpts) logf(format string, args ...interface{}) {
+	fmt.Fprintf(o.logWriter(), format, args...)
+}
+
+// sharedAssetsDir 返回 assets_layout 为 shared 时图片的存放目录，否则返回空字符串
+func (o *DownloadOpts) sharedAssetsDir() string {
+	if dlConfig.Output.AssetsLayout != "shared" {
+		return ""
+	}
+	root := o.assetsRoot
+	if root == "" {
+		root = o.outputDir
+	}
+	return filepath.Join(root, dlConfig.Output.AssetsDir)
+}
+
+// saveAsset 将下载的图片保存到共享目录（按内容哈希去重）或文档自身的图片目录
+func saveAsset(filename string, data []byte, imageDir, assetsDir string) (string, error) {
+	if assetsDir != "" {
+		return utils.WriteContentAddressed(assetsDir, filepath.Ext(filename), data)
+	}
+	path := filepath.Join(imageDir, filepath.Base(filename))
+	if err := os.MkdirAll(imageDir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// mediaJob 表示文档中一张待下载的图片或画板
+type mediaJob struct {
+	token    string
+	label    string
+	download func(ctx context.Context, token, imgDir string) (string, []byte, error)
+	link     string // 图片在 markdown 中的链接：相对路径或 data URI
+	err      error
+}
+
+// downloadMedia 使用大小为 image_concurrency 的协程池下载文档中的图片，
+// 所有请求仍经过 client 的全局限流器；同一 token 只下载一次。
+// image_mode 为 data_uri 时不超过大小上限的图片直接内嵌，其余图片保存到本地
+func downloadMedia(ctx context.Context, jobs []mediaJob, opts *DownloadOpts, imageDir string) {
+	concurrency := dlConfig.Output.ImageConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	assetsDir := opts.sharedAssetsDir()
+
+	// 先去重，每个 token 在 results 中有自己的位置，协程之间不共享 map
+	slots := make(map[string]int)
+	unique := make([]mediaJob, 0, len(jobs))
+	for _, job := range jobs {
+		if _, ok := slots[job.token]; ok {
+			continue
+		}
+		slots[job.token] = len(unique)
+		unique = append(unique, job)
+	}
+	type result struct {
+		link string
+		err  error
+	}
+	results := make([]result, len(unique))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for i, job := range unique {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, job mediaJob) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			filename, data, err := job.download(ctx, job.token, imageDir)
+			if err == nil {
+				// 处理失败时保留原图，仅给出警告
+				var perr error
+				filename, data, perr = core.ProcessImage(filename, data, dlConfig.Output)
+				if perr != nil {
+					opts.logf("  ⚠️  %s %s 处理失败，保留原图: %v\n", job.label, job.token, perr)
+				}
+				if dlConfig.Output.ImageMode == "data_uri" && len(data) <= dlConfig.Output.DataURIMaxBytes {
+					filename = core.DataURI(filename, data)
+				} else if filename, err = saveAsset(filename, data, imageDir, assetsDir); err == nil {
+					filename = assetLink(opts.outputDir, filename)
+				}
+			}
+			results[i] = result{link: filename, err: err}
+		}(i, job)
+	}
+	wg.Wait()
+	for i := range jobs {
+		r := results[slots[jobs[i].token]]
+		jobs[i].link, jobs[i].err = r.link, r.err
+	}
+}
+
+var imageMarkupTemplate = `!\[[^\]]*\]\(%[1]s\)(\{[^}]*\})?|<img src="%[1]s"[^>]*/>`
+
+// replaceImageMarkup 将引用 token 的第一处图片标记（Markdown 或 HTML）替换为 placeholder
+func replaceImageMarkup(markdown, token, placeholder string) string {
+	re := regexp.MustCompile(fmt.Sprintf(imageMarkupTemplate, regexp.QuoteMeta(token)))
+	loc := re.FindStringIndex(markdown)
+	if loc == nil {
+		return markdown
+	}
+	return markdown[:loc[0]] + placeholder + markdown[loc[1]:]
+}
+
+// assetLink 计算图片相对于 markdown 文件的链接
+func assetLink(outputDir, path string) string {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		rel = path
+	}
+	return filepath.ToSlash(rel)
 }
 
 var dlOpts = DownloadOpts{}
 var dlConfig core.Config
 
+// unsupportedReport 汇总本次运行中各文档未能转换的块，运行结束时统一打印
+type unsupportedReport struct {
+	mu     sync.Mutex
+	counts map[lark.DocxBlockType]int
+	docs   map[lark.DocxBlockType][]string
+}
+
+var dlUnsupported = &unsupportedReport{}
+
+func (r *unsupportedReport) add(docName string, blocks []core.UnsupportedBlock) {
+	if len(blocks) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts == nil {
+		r.counts = make(map[lark.DocxBlockType]int)
+		r.docs = make(map[lark.DocxBlockType][]string)
+	}
+	seen := make(map[lark.DocxBlockType]bool)
+	for _, b := range blocks {
+		r.counts[b.BlockType]++
+		if !seen[b.BlockType] {
+			seen[b.BlockType] = true
+			r.docs[b.BlockType] = append(r.docs[b.BlockType], docName)
+		}
+	}
+}
+
+func (r *unsupportedReport) print() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.counts) == 0 {
+		return
+	}
+	types := make([]lark.DocxBlockType, 0, len(r.counts))
+	for t := range r.counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	fmt.Println("\n=== 未支持的块 ===")
+	for _, t := range types {
+		fmt.Printf("  type=%d: %d 个，涉及文档: %s\n", t, r.counts[t], strings.Join(r.docs[t], ", "))
+	}
+}
+
+// syncedSourceCache 缓存同步块引用的源文档，保证一次运行中每个源文档只拉取一次
+type syncedSourceCache struct {
+	mu      sync.Mutex
+	entries map[string]*syncedSourceEntry
+}
+
+type syncedSourceEntry struct {
+	once   sync.Once
+	source *core.SyncedSource
+	err    error
+}
+
+var dlSyncedSources = &syncedSourceCache{}
+
+func (c *syncedSourceCache) get(ctx context.Context, client *core.Client, docID string) (*core.SyncedSource, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*syncedSourceEntry)
+	}
+	entry, ok := c.entries[docID]
+	if !ok {
+		entry = &syncedSourceEntry{}
+		c.entries[docID] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		docx, blocks, exts, err := client.GetDocxContentExt(ctx, docID)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		entry.source = &core.SyncedSource{Title: docx.Title, Blocks: blocks, BlockExts: exts}
+	})
+	return entry.source, entry.err
+}
+
+// exportedDocs 记录本次运行中各文档（按 document ID）导出的 markdown 路径，
+// 同步块以嵌入方式引用源文档时用于定位实际的文件名
+type exportedDocs struct {
+	mu    sync.Mutex
+	paths map[string]string
+}
+
+var dlExportedDocs = &exportedDocs{}
+
+func (e *exportedDocs) set(docID, path string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.paths == nil {
+		e.paths = make(map[string]string)
+	}
+	e.paths[docID] = path
+}
+
+func (e *exportedDocs) get(docID string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paths[docID]
+}
+
+// resolveSyncedSources 拉取文档中同步块引用的源文档，已导出（或即将导出）的源文档
+// 带上相对于 opts.outputDir 的链接
+func resolveSyncedSources(ctx context.Context, client *core.Client, docID string, exts map[string]*core.DocxBlockExt, opts *DownloadOpts) map[string]*core.SyncedSource {
+	sources := make(map[string]*core.SyncedSource)
+	for _, id := range core.SyncedSourceDocumentIDs(exts, docID) {
+		source, err := dlSyncedSources.get(ctx, client, id)
+		if err != nil {
+			opts.logf("  ⚠️  同步块源文档 %s 拉取失败: %v\n", id, err)
+			continue
+		}
+		if path := dlExportedDocs.get(id); path != "" {
+			// 缓存中的源文档被多个文档共用，链接按引用方单独设置
+			linked := *source
+			linked.Link = strings.TrimSuffix(assetLink(opts.outputDir, path), ".md")
+			source = &linked
+		}
+		sources[id] = source
+	}
+	return sources
+}
+
+// renderedDocument 是已渲染、尚未写入磁盘的文档
+type renderedDocument struct {
+	docToken  string
+	docx      *lark.DocxDocument
+	blocks    []*lark.DocxBlock
+	blockExts map[string]*core.DocxBlockExt
+	comments  []*core.Comment
+	// mediaTokens 列出文档中的图片和画板，跳过下载时它们在 markdown 中保持为 token
+	mediaTokens []string
+	body        string // 不含 front matter 的 markdown
+	markdown    string // 写入文件的完整内容
+	mdName      string
+}
+
 func downloadDocument(ctx context.Context, client *core.Client, url string, opts *DownloadOpts) (string, error) {
+	doc, err := renderDocument(ctx, client, url, opts)
+	if err != nil {
+		return "", err
+	}
+	if err := writeDocument(doc, opts); err != nil {
+		return "", err
+	}
+	return doc.mdName, nil
+}
+
+// renderDocument 拉取并渲染文档（opts.revision 不为 0 时渲染该历史版本），图片按配置下载到本地
+func renderDocument(ctx context.Context, client *core.Client, url string, opts *DownloadOpts) (*renderedDocument, error) {
 	// Validate the url to download
 	docType, docToken, err := utils.ValidateDocumentURL(url)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	fmt.Println("获取文档令牌:", docToken)
+	opts.logf("获取文档令牌: %s\n", docToken)
 
 	// for a wiki page, we need to renew docType and docToken first
+	var wikiNode *lark.GetWikiNodeRespNode
 	if docType == "wiki" {
 		node, err := client.GetWikiNodeInfo(ctx, docToken)
 		if err != nil {
@@ -45,18 +349,34 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 		utils.CheckErr(err)
 		docType = node.ObjType
 		docToken = node.ObjToken
+		wikiNode = node
 	}
 	if docType == "docs" {
-		return "", errors.Errorf(
+		return nil, errors.Errorf(
 			`Feishu Docs is no longer supported. ` +
 				`Please refer to the Readme/Release for v1_support.`)
 	}
 
 	// Process the download
-	docx, blocks, err := client.GetDocxContent(ctx, docToken)
+	docx, blocks, blockExts, err := client.GetDocxContentAtRevision(ctx, docToken, opts.revision)
+	if err != nil && opts.revision != 0 {
+		return nil, fmt.Errorf("获取文档 %s 的版本 %d 失败: %v", docToken, opts.revision, err)
+	}
 	utils.CheckErr(err)
 
-	parser := core.NewParser(dlConfig.Output)
+	outputConfig := dlConfig.Output
+	if opts.bitableMaxRows != nil {
+		outputConfig.BitableMaxRows = *opts.bitableMaxRows
+	}
+	if opts.textOnly {
+		outputConfig.Comments = ""
+		outputConfig.CommentsJSON = false
+		outputConfig.InlineBitable = false
+	}
+	parser := core.NewParser(outputConfig)
+	parser.SetBlockExts(blockExts)
+	parser.SetSourceURL(url)
+	parser.SetSyncedSources(resolveSyncedSources(ctx, client, docx.DocumentID, blockExts, opts))
 
 	// Collect @mention user OpenIDs, resolve to display names, and set on parser
 	collectMentionOpenIDs := func(blocks []*lark.DocxBlock) []string {
@@ -135,7 +455,7 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 	}
 	mentionIDs := collectMentionOpenIDs(blocks)
 	if len(mentionIDs) > 0 {
-		fmt.Printf("  发现 %d 个 @提及用户，开始解析...\n", len(mentionIDs))
+		opts.logf("  发现 %d 个 @提及用户，开始解析...\n", len(mentionIDs))
 		nameMap := client.ResolveUserNames(ctx, mentionIDs)
 		parser.SetMentionUserMap(nameMap)
 		// Debug summary to help diagnose permission/config issues
@@ -149,19 +469,31 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 			}
 		}
 		if len(unresolvedList) > 0 {
-			fmt.Printf("  @提及解析: %d/%d 成功，未解析: %v\n", resolved, len(mentionIDs), unresolvedList)
+			opts.logf("  @提及解析: %d/%d 成功，未解析: %v\n", resolved, len(mentionIDs), unresolvedList)
 			if resolved == 0 {
-				fmt.Printf("  💡 提示: 要获取正确的用户名，请:\n")
-				fmt.Printf("     1. 在飞书开放平台为应用添加 'contact:user.base:readonly' 权限\n")
-				fmt.Printf("     2. 或使用飞书网页版导出文档功能 (文件 > 导出 > Word)\n")
+				opts.logf("  💡 提示: 要获取正确的用户名，请:\n")
+				opts.logf("     1. 在飞书开放平台为应用添加 'contact:user.base:readonly' 权限\n")
+				opts.logf("     2. 或使用飞书网页版导出文档功能 (文件 > 导出 > Word)\n")
 			}
 		} else {
-			fmt.Printf("  @提及解析: %d/%d 成功\n", resolved, len(mentionIDs))
+			opts.logf("  @提及解析: %d/%d 成功\n", resolved, len(mentionIDs))
+		}
+	}
+
+	// 拉取文档评论并解析评论者姓名；评论拉取失败不影响文档本身的导出
+	var comments []*core.Comment
+	if outputConfig.Comments != "" || outputConfig.CommentsJSON {
+		comments, err = client.GetDocxComments(ctx, docx.DocumentID)
+		if err != nil {
+			opts.logf("  ⚠️  评论拉取失败: %v\n", err)
+		} else {
+			core.SetCommentUserNames(comments, client.ResolveUserNames(ctx, core.CommentUserIDs(comments)))
+			parser.SetComments(comments)
+			opts.logf("  获取到 %d 条评论\n", len(comments))
 		}
 	}
 
 	title := docx.Title
-	markdown := parser.ParseDocxContent(docx, blocks)
 
 	// Determine document name for image folder
 	var docName string
@@ -179,81 +511,146 @@ func downloadDocument(ctx context.Context, client *core.Client, url string, opts
 		docName = docToken
 	}
 
+	if outputConfig.InlineBitable {
+		assetDir := filepath.Join(opts.outputDir, docName)
+		if opts.dryRun {
+			assetDir = ""
+		}
+		tables := loadEmbeddedBitables(ctx, client, blocks, assetDir, docName, false, opts.logWriter())
+		parser.SetBitableTables(tables)
+	}
+
+	markdown := parser.ParseDocxContent(docx, blocks)
+	dlUnsupported.add(title, parser.Unsupported)
+
 	// 检查是否跳过图片下载：opts.skipImages 优先于配置文件中的设置
-	shouldSkipImages := opts.skipImages || dlConfig.Output.SkipImgDownload
+	shouldSkipImages := opts.skipImages || dlConfig.Output.SkipImgDownload || opts.dryRun
+	mediaTokens := append(append([]string{}, parser.ImgTokens...), parser.BoardTokens...)
 
-	if !shouldSkipImages {
+	if dlConfig.Output.ImageMode == "remote" || opts.textOnly {
+		// 不下载图片，直接引用飞书上的地址；画板没有可直接引用的图片地址，回退为原文链接
+		for _, imgToken := range parser.ImgTokens {
+			markdown = strings.Replace(markdown, imgToken, parser.RemoteMediaURL(imgToken), 1)
+		}
+		for _, boardToken := range parser.BoardTokens {
+			markdown = replaceImageMarkup(markdown, boardToken, parser.SourceLink("画板", ""))
+		}
+	} else if !shouldSkipImages {
 		// Create document-specific image directory
 		imageDir := filepath.Join(opts.outputDir, docName)
-
+		jobs := make([]mediaJob, 0, len(parser.ImgTokens)+len(parser.BoardTokens))
 		for _, imgToken := range parser.ImgTokens {
-			localLink, err := client.DownloadImage(
-				ctx, imgToken, imageDir,
-			)
-			if err != nil {
-				return "", err
+			jobs = append(jobs, mediaJob{token: imgToken, label: "图片", download: client.DownloadImageRaw})
+		}
+		for _, boardToken := range parser.BoardTokens {
+			jobs = append(jobs, mediaJob{token: boardToken, label: "画板", download: client.DownloadBoardImageRaw})
+		}
+		downloadMedia(ctx, jobs, opts, imageDir)
+
+		// 下载失败的图片和画板回退为指向飞书原文的链接，不影响文档其余部分
+		for _, job := range jobs {
+			if job.err != nil {
+				opts.logf("  ⚠️  %s %s 下载失败: %v\n", job.label, job.token, job.err)
+				markdown = replaceImageMarkup(markdown, job.token, parser.SourceLink(job.label, ""))
+				continue
 			}
-			// Update the image path to be relative to the markdown file
-			relPath := filepath.Join(docName, filepath.Base(localLink))
-			markdown = strings.Replace(markdown, imgToken, relPath, 1)
+			markdown = strings.Replace(markdown, job.token, job.link, 1)
 		}
 	} else {
-		fmt.Printf("  跳过图片下载（共 %d 张图片）\n", len(parser.ImgTokens))
+		opts.logf("  跳过图片下载（共 %d 张图片）\n", len(parser.ImgTokens)+len(parser.BoardTokens))
 	}
 
 	// Format the markdown document
 	engine := lute.New(func(l *lute.Lute) {
 		l.RenderOptions.AutoSpace = true
 	})
-	result := engine.FormatStr("md", markdown)
+	body := engine.FormatStr("md", markdown)
+	result := body
 
+	if dlConfig.Output.FrontMatter != "" {
+		frontMatter, err := buildFrontMatter(ctx, client, docx, url, wikiNode, opts)
+		if err != nil {
+			return nil, err
+		}
+		result = frontMatter + result
+	}
+
+	// Determine the markdown file name
+	var mdName string
+	if opts.useOriginalTitle {
+		// 使用飞书文档的原始标题
+		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(title))
+	} else if opts.docName != "" {
+		// Use the provided document name from config
+		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(opts.docName))
+	} else if dlConfig.Output.TitleAsFilename {
+		// Use title as filename if configured
+		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(title))
+	} else {
+		// Default to token as filename
+		mdName = fmt.Sprintf("%s.md", docToken)
+	}
+	if opts.revision > 0 {
+		mdName = fmt.Sprintf("%s.r%d.md", strings.TrimSuffix(mdName, ".md"), opts.revision)
+	}
+
+	return &renderedDocument{
+		docToken:    docToken,
+		docx:        docx,
+		blocks:      blocks,
+		blockExts:   blockExts,
+		comments:    comments,
+		body:        body,
+		mediaTokens: mediaTokens,
+		markdown:    result,
+		mdName:      mdName,
+	}, nil
+}
+
+func writeDocument(doc *renderedDocument, opts *DownloadOpts) error {
 	// Handle the output directory and name
 	if _, err := os.Stat(opts.outputDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(opts.outputDir, 0o755); err != nil {
-			return "", err
+			return err
 		}
 	}
 
 	if dlOpts.dump {
-		jsonName := fmt.Sprintf("%s.json", docToken)
+		jsonName := fmt.Sprintf("%s.json", doc.docToken)
 		outputPath := filepath.Join(opts.outputDir, jsonName)
 		data := struct {
-			Document *lark.DocxDocument `json:"document"`
-			Blocks   []*lark.DocxBlock  `json:"blocks"`
+			Document  *lark.DocxDocument            `json:"document"`
+			Blocks    []*lark.DocxBlock             `json:"blocks"`
+			BlockExts map[string]*core.DocxBlockExt `json:"block_exts,omitempty"`
 		}{
-			Document: docx,
-			Blocks:   blocks,
+			Document:  doc.docx,
+			Blocks:    doc.blocks,
+			BlockExts: doc.blockExts,
 		}
 		pdata := utils.PrettyPrint(data)
 
-		if err = os.WriteFile(outputPath, []byte(pdata), 0o644); err != nil {
-			return "", err
+		if err := os.WriteFile(outputPath, []byte(pdata), 0o644); err != nil {
+			return err
 		}
 		fmt.Printf("Dumped json response to %s\n", outputPath)
 	}
 
 	// Write to markdown file
-	var mdName string
-	if opts.useOriginalTitle {
-		// 使用飞书文档的原始标题
-		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(title))
-	} else if opts.docName != "" {
-		// Use the provided document name from config
-		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(opts.docName))
-	} else if dlConfig.Output.TitleAsFilename {
-		// Use title as filename if configured
-		mdName = fmt.Sprintf("%s.md", utils.SanitizeFileName(title))
-	} else {
-		// Default to token as filename
-		mdName = fmt.Sprintf("%s.md", docToken)
-	}
-	outputPath := filepath.Join(opts.outputDir, mdName)
-	if err = os.WriteFile(outputPath, []byte(result), 0o644); err != nil {
-		return "", err
+	outputPath := filepath.Join(opts.outputDir, doc.mdName)
+	if err := os.WriteFile(outputPath, []byte(doc.markdown), 0o644); err != nil {
+		return err
 	}
 	fmt.Printf("已下载 markdown 文件到 %s\n", outputPath)
 
-	return mdName, nil
+	if dlConfig.Output.CommentsJSON && doc.comments != nil {
+		commentsPath := strings.TrimSuffix(outputPath, ".md") + ".comments.json"
+		if err := os.WriteFile(commentsPath, []byte(utils.PrettyPrint(doc.comments)), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("已导出评论到 %s\n", commentsPath)
+	}
+
+	return nil
 }
 
 func downloadDocuments(ctx context.Context, client *core.Client, url string) error {
@@ -267,6 +664,11 @@ func downloadDocuments(ctx context.Context, client *core.Client, url string) err
 	// Error channel and wait group
 	errChan := make(chan error)
 	wg := sync.WaitGroup{}
+	// 与知识库下载相同，同时下载的文档和导出的非新版文档最多 10 个
+	semaphore := make(chan struct{}, 10)
+	// 遍历完整个文件夹、登记所有文档的导出路径后才开始下载，
+	// 同步块引用的源文档是否已导出不取决于下载顺序
+	pending := make([]func(), 0)
 
 	// Recursively go through the folder and download the documents
 	var processFolder func(ctx context.Context, folderPath, folderToken string) error
@@ -290,15 +692,32 @@ func downloadDocuments(ctx context.Context, client *core.Client, url string) err
 					docName:          file.Name,
 					skipImages:       dlOpts.skipImages, // 继承父级的skipImages设置
 					useOriginalTitle: false,             // 在folder下载中使用文件名，不使用原始标题
+					assetsRoot:       dlOpts.outputDir,
 				}
+				dlExportedDocs.set(file.Token, filepath.Join(folderPath, utils.SanitizeFileName(file.Name)+".md"))
 				// concurrently download the document
+				pending = append(pending, func() {
+					wg.Add(1)
+					go func(_url string) {
+						semaphore <- struct{}{}
+						if _, err := downloadDocument(ctx, client, _url, &opts); err != nil {
+							errChan <- err
+						}
+						<-semaphore
+						wg.Done()
+					}(file.URL)
+				})
+			} else if nonDocxHandler(file.Type) != "skip" {
+				item := nonDocxItem{objType: file.Type, token: file.Token, title: file.Name, name: file.Name, url: file.URL}
 				wg.Add(1)
-				go func(_url string) {
-					if _, err := downloadDocument(ctx, client, _url, &opts); err != nil {
+				go func() {
+					semaphore <- struct{}{}
+					if err := exportNonDocx(ctx, client, item, folderPath); err != nil {
 						errChan <- err
 					}
+					<-semaphore
 					wg.Done()
-				}(file.URL)
+				}()
 			}
 		}
 		return nil
@@ -306,6 +725,9 @@ func downloadDocuments(ctx context.Context, client *core.Client, url string) err
 	if err := processFolder(ctx, dlOpts.outputDir, folderToken); err != nil {
 		return err
 	}
+	for _, start := range pending {
+		start()
+	}
 
 	// Wait for all the downloads to finish
 	go func() {
@@ -318,76 +740,187 @@ func downloadDocuments(ctx context.Context, client *core.Client, url string) err
 	return nil
 }
 
-func downloadWiki(ctx context.Context, client *core.Client, url string) error {
-	prefixURL, spaceID, err := utils.ValidateWikiURL(url)
-	if err != nil {
+// downloadWiki 下载知识库空间（设置页链接）或某个知识库页面及其子页面，
+// filter 按标题/路径通配符和层级筛选要下载的节点，被排除的子树不会再列出；
+// site 按知识库中的顺序生成静态站点的导航文件
+func downloadWiki(ctx context.Context, client *core.Client, url string, filter *utils.TreeFilter, site string) error {
+	if err := validSite(site); err != nil {
 		return err
 	}
-
-	folderPath, err := client.GetWikiName(ctx, spaceID)
-	if err != nil {
-		return err
-	}
-	if folderPath == "" {
-		return fmt.Errorf("failed to GetWikiName")
+	var prefixURL, spaceID, folderPath string
+	var startNode *lark.GetWikiNodeRespNode
+	if strings.Contains(url, "/wiki/settings/") {
+		var err error
+		prefixURL, spaceID, err = utils.ValidateWikiURL(url)
+		if err != nil {
+			return err
+		}
+		folderPath, err = client.GetWikiName(ctx, spaceID)
+		if err != nil {
+			return err
+		}
+		if folderPath == "" {
+			return fmt.Errorf("failed to GetWikiName")
+		}
+	} else {
+		docType, nodeToken, err := utils.ValidateDocumentURL(url)
+		if err != nil || docType != "wiki" {
+			return fmt.Errorf("invalid wiki url: %s", url)
+		}
+		startNode, err = client.GetWikiNodeInfo(ctx, nodeToken)
+		if err != nil {
+			return err
+		}
+		prefixURL, spaceID, folderPath = urlOrigin(url), startNode.SpaceID, startNode.Title
 	}
+	folderPath = filepath.Join(dlOpts.outputDir, folderPath)
 
 	errChan := make(chan error)
+	rootPath := folderPath
 
 	var maxConcurrency = 10 // Set the maximum concurrency level
 	wg := sync.WaitGroup{}
 	semaphore := make(chan struct{}, maxConcurrency) // Create a semaphore with the maximum concurrency level
 
-	var downloadWikiNode func(ctx context.Context,
-		client *core.Client,
-		spaceID string,
-		parentPath string,
-		parentNodeToken *string) error
-
-	downloadWikiNode = func(ctx context.Context,
-		client *core.Client,
-		spaceID string,
-		folderPath string,
-		parentNodeToken *string) error {
+	// 文档在遍历完整棵树、登记所有文档的导出路径后才开始下载，
+	// 同步块引用的源文档是否已导出不取决于下载顺序
+	pending := make([]func(), 0)
+	downloadNode := func(folderPath, title, nodeToken string) {
+		// Use node title as document name for image folder
+		opts := DownloadOpts{
+			outputDir:        folderPath,
+			dump:             dlOpts.dump,
+			batch:            false,
+			docName:          title,
+			skipImages:       dlOpts.skipImages, // 继承父级的skipImages设置
+			useOriginalTitle: false,             // 在wiki下载中使用节点标题，不使用原始标题
+			assetsRoot:       rootPath,
+		}
+		pending = append(pending, func() {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(_url string) {
+				if _, err := downloadDocument(ctx, client, _url, &opts); err != nil {
+					errChan <- err
+				}
+				wg.Done()
+				<-semaphore
+			}(prefixURL + "/wiki/" + nodeToken)
+		})
+	}
+
+	exportNode := func(folderPath string, item nonDocxItem) {
+		if nonDocxHandler(item.objType) == "skip" {
+			return
+		}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			if err := exportNonDocx(ctx, client, item, folderPath); err != nil {
+				errChan <- err
+			}
+			wg.Done()
+			<-semaphore
+		}()
+	}
+
+	// parent_page_layout 不为 sibling 时，有子页面的节点内容写为其目录下的索引文件
+	indexName := wikiIndexName(dlConfig.Output.ParentPageLayout)
+
+	// downloadWikiNode 按知识库中的顺序下载子节点，返回子节点的导航树
+	var downloadWikiNode func(folderPath string, parentNodeToken *string, titles []string) ([]wikiNavEntry, error)
+	downloadWikiNode = func(folderPath string, parentNodeToken *string, titles []string) ([]wikiNavEntry, error) {
 		nodes, err := client.GetWikiNodeList(ctx, spaceID, parentNodeToken)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		for _, n := range nodes {
-			if n.HasChild {
-				_folderPath := filepath.Join(folderPath, n.Title)
-				if err := downloadWikiNode(ctx, client,
-					spaceID, _folderPath, &n.NodeToken); err != nil {
-					return err
+		entries := make([]wikiNavEntry, 0, len(nodes))
+		for i, n := range nodes {
+			nodeTitles := append(titles[:len(titles):len(titles)], n.Title)
+			if filter.Excluded(nodeTitles) {
+				continue
+			}
+			name := n.Title
+			if site == "prefix" {
+				// 序号取节点在知识库中的位置，筛选掉部分节点时保持不变
+				name = fmt.Sprintf("%0*d-%s", max(2, len(strconv.Itoa(len(nodes)))), i+1, n.Title)
+			}
+			entry := wikiNavEntry{title: n.Title, position: i + 1, url: prefixURL + "/wiki/" + n.NodeToken}
+			_folderPath := filepath.Join(folderPath, name)
+			descend := n.HasChild && filter.Descend(nodeTitles, len(nodeTitles))
+			if descend {
+				if entry.children, err = downloadWikiNode(_folderPath, &n.NodeToken, nodeTitles); err != nil {
+					return nil, err
+				}
+				if len(entry.children) > 0 {
+					entry.folder = _folderPath
 				}
 			}
-			if n.ObjType == "docx" {
-				// Use node title as document name for image folder
-				opts := DownloadOpts{
-					outputDir:        folderPath,
-					dump:             dlOpts.dump,
-					batch:            false,
-					docName:          n.Title,
-					skipImages:       dlOpts.skipImages, // 继承父级的skipImages设置
-					useOriginalTitle: false,             // 在wiki下载中使用节点标题，不使用原始标题
+			included := filter.Included(nodeTitles)
+			item := nonDocxItem{objType: n.ObjType, token: n.ObjToken, title: n.Title, name: name, url: entry.url}
+			switch {
+			case n.ObjType == "docx" && included && descend && indexName != "":
+				entry.path = filepath.Join(_folderPath, indexName+".md")
+				entry.folder = _folderPath
+				dlExportedDocs.set(n.ObjToken, entry.path)
+				downloadNode(_folderPath, indexName, n.NodeToken)
+			case n.ObjType == "docx" && included:
+				entry.path = filepath.Join(folderPath, utils.SanitizeFileName(name)+".md")
+				dlExportedDocs.set(n.ObjToken, entry.path)
+				downloadNode(folderPath, name, n.NodeToken)
+			case entry.folder != "" && indexName != "":
+				// 非新版文档（或未选中）的父节点没有 Markdown 内容，生成子页面列表作为索引，
+				// 电子表格等按类型导出到目录中
+				entry.path = filepath.Join(_folderPath, indexName+".md")
+				if err := writeWikiIndex(entry.path, n.Title, entry.children); err != nil {
+					return nil, err
 				}
-				wg.Add(1)
-				semaphore <- struct{}{}
-				go func(_url string) {
-					if _, err := downloadDocument(ctx, client, _url, &opts); err != nil {
-						errChan <- err
-					}
-					wg.Done()
-					<-semaphore
-				}(prefixURL + "/wiki/" + n.NodeToken)
-				// downloadDocument(ctx, client, prefixURL+"/wiki/"+n.NodeToken, &opts)
+				if included && n.ObjType != "docx" && nonDocxHandler(n.ObjType) != "stub" {
+					exportNode(_folderPath, item)
+				}
+			case included && n.ObjType != "docx" && nonDocxHandler(n.ObjType) == "stub":
+				if entry.path, err = writeNonDocxStub(item, folderPath); err != nil {
+					return nil, err
+				}
+			case included && n.ObjType != "docx":
+				exportNode(folderPath, item)
+			case entry.folder == "" && !included:
+				continue
 			}
+			entries = append(entries, entry)
 		}
-		return nil
+		return entries, nil
 	}
 
-	if err = downloadWikiNode(ctx, client, spaceID, folderPath, nil); err != nil {
-		return err
+	root := wikiNavEntry{title: filepath.Base(folderPath), folder: folderPath}
+	var parentNodeToken *string
+	if startNode != nil {
+		root.title = startNode.Title
+		parentNodeToken = &startNode.NodeToken
+		if startNode.ObjType == "docx" && (filter == nil || len(filter.Include) == 0) {
+			if indexName != "" {
+				downloadNode(folderPath, indexName, startNode.NodeToken)
+				root.path = filepath.Join(folderPath, indexName+".md")
+				dlExportedDocs.set(startNode.ObjToken, root.path)
+			} else {
+				// 起始页面本身与其子页面目录同名，放在目录旁边
+				downloadNode(filepath.Dir(folderPath), startNode.Title, startNode.NodeToken)
+				dlExportedDocs.set(startNode.ObjToken, filepath.Join(filepath.Dir(folderPath), utils.SanitizeFileName(startNode.Title)+".md"))
+			}
+		}
+	}
+	var walkErr error
+	if filter.Descend(nil, 0) {
+		root.children, walkErr = downloadWikiNode(folderPath, parentNodeToken, nil)
+		if walkErr == nil && indexName != "" && root.path == "" && len(root.children) > 0 {
+			root.path = filepath.Join(folderPath, indexName+".md")
+			walkErr = writeWikiIndex(root.path, root.title, root.children)
+		}
+	}
+	if walkErr == nil {
+		for _, start := range pending {
+			start()
+		}
 	}
 
 	// Wait for all the downloads to finish
@@ -395,9 +928,62 @@ func downloadWiki(ctx context.Context, client *core.Client, url string) error {
 		wg.Wait()
 		close(errChan)
 	}()
+	var downloadErr error
 	for err := range errChan {
+		if downloadErr == nil {
+			downloadErr = err
+		}
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+	if downloadErr != nil {
+		return downloadErr
+	}
+	return writeSiteNav(site, &root)
+}
+
+// wikiNavEntry 是知识库导航树中的一个节点
+type wikiNavEntry struct {
+	title    string
+	position int    // 节点在父节点下的顺序，从 1 开始
+	path     string // 导出的 markdown 文件，没有导出内容时为空
+	url      string // 飞书中的链接
+	folder   string // 子页面所在的目录，没有子页面时为空
+	children []wikiNavEntry
+}
+
+// wikiIndexName 返回 parent_page_layout 对应的索引文件名（不含扩展名），sibling 布局返回空字符串
+func wikiIndexName(layout string) string {
+	switch layout {
+	case "index":
+		return "index"
+	case "_index":
+		return "_index"
+	case "readme":
+		return "README"
+	}
+	return ""
+}
+
+// writeWikiIndex 为没有可导出内容的父节点生成列出子页面的索引文件
+func writeWikiIndex(outputPath, title string, entries []wikiNavEntry) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "# %s\n\n", title)
+	for _, e := range entries {
+		link := e.url
+		if e.path != "" {
+			link = (&url.URL{Path: assetLink(filepath.Dir(outputPath), e.path)}).String()
+		}
+		fmt.Fprintf(buf, "- [%s](%s)\n", e.title, link)
+	}
+	if err := os.WriteFile(outputPath, []byte(buf.String()), 0o644); err != nil {
 		return err
 	}
+	fmt.Printf("已生成目录索引 %s\n", outputPath)
 	return nil
 }
 
@@ -412,6 +998,15 @@ func handleDownloadCommand(url string) error {
 		return err
 	}
 	dlConfig = *config
+	if dlOpts.comments != "" {
+		dlConfig.Output.Comments = dlOpts.comments
+	}
+	if dlOpts.commentsJSON {
+		dlConfig.Output.CommentsJSON = true
+	}
+	if err := dlConfig.Output.Validate(); err != nil {
+		return err
+	}
 
 	// Instantiate the client
 	client := core.NewClient(
@@ -419,12 +1014,22 @@ func handleDownloadCommand(url string) error {
 	)
 	ctx := context.Background()
 
+	defer dlUnsupported.print()
+
 	if dlOpts.batch {
 		return downloadDocuments(ctx, client, url)
 	}
 
 	if dlOpts.wiki {
-		return downloadWiki(ctx, client, url)
+		return downloadWiki(ctx, client, url, &utils.TreeFilter{
+			Include:  dlOpts.include,
+			Exclude:  dlOpts.exclude,
+			MaxDepth: dlOpts.maxDepth,
+		}, dlOpts.site)
+	}
+
+	if dlOpts.history {
+		return downloadHistory(ctx, client, url, &dlOpts)
 	}
 
 	_, err = downloadDocument(ctx, client, url, &dlOpts)
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+)
+
+// buildFrontMatter 根据配置生成文档的 front matter，只在字段需要时才请求云空间元数据和知识库路径
+func buildFrontMatter(ctx context.Context, client *core.Client, docx *lark.DocxDocument, url string,
+	wikiNode *lark.GetWikiNodeRespNode, opts *DownloadOpts) (string, error) {
+	fields := dlConfig.Output.FrontMatterFields
+	meta := &core.DocumentMeta{
+		Title:      docx.Title,
+		DocumentID: docx.DocumentID,
+		RevisionID: docx.RevisionID,
+		SourceURL:  url,
+		Group:      opts.group,
+	}
+
+	if wikiNode != nil && containsField(fields, "wiki_path") {
+		path, err := client.GetWikiNodePath(ctx, wikiNode)
+		if err != nil {
+			fmt.Printf("  ⚠️  获取知识库路径失败: %v\n", err)
+		}
+		meta.WikiPath = path
+	}
+
+	if core.FrontMatterNeedsDriveMeta(fields) {
+		fileMeta, err := client.GetDriveFileMeta(ctx, docx.DocumentID, "docx")
+		if err != nil {
+			fmt.Printf("  ⚠️  获取文档元数据失败: %v\n", err)
+		} else {
+			meta.CreatedTime = parseUnixTime(fileMeta.CreateTime)
+			meta.UpdatedTime = parseUnixTime(fileMeta.LatestModifyTime)
+			meta.Owner = fileMeta.OwnerID
+			if fileMeta.OwnerID != "" {
+				if name := client.ResolveUserNames(ctx, []string{fileMeta.OwnerID})[fileMeta.OwnerID]; name != "" {
+					meta.Owner = name
+				}
+			}
+		}
+	}
+
+	return core.RenderFrontMatter(dlConfig.Output.FrontMatter, fields, meta, opts.frontMatter)
+}
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parseUnixTime 解析接口返回的秒级时间戳字符串，无法解析时返回零值
+func parseUnixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Wsine/feishu2md/core"
+)
+
+// downloadHistory 导出文档的历史版本：内容有变化的版本各保存一份快照，
+// 并生成 CHANGELOG.md 记录每个版本改动了哪些标题下的内容。
+// 快照只渲染正文，不下载图片、评论和内嵌多维表格，未变化的版本不写入任何文件
+func downloadHistory(ctx context.Context, client *core.Client, url string, opts *DownloadOpts) error {
+	latestOpts := *opts
+	latestOpts.revision = 0
+	latestOpts.dryRun = true
+	latestOpts.textOnly = true
+	latest, err := renderDocument(ctx, client, url, &latestOpts)
+	if err != nil {
+		return err
+	}
+	latestRevision := latest.docx.RevisionID
+	name := strings.TrimSuffix(latest.mdName, ".md")
+	historyDir := filepath.Join(opts.outputDir, name+".history")
+	firstRevision := int64(1)
+	if opts.historyLimit > 0 && latestRevision > int64(opts.historyLimit) {
+		firstRevision = latestRevision - int64(opts.historyLimit) + 1
+	}
+	fmt.Printf("导出文档 %s 的 %d 个历史版本到 %s\n", latest.docx.Title, latestRevision-firstRevision+1, historyDir)
+
+	revisions := make([]core.RevisionChanges, 0)
+	previous := ""
+	// 只导出最近的版本时，第一个版本与它的前一个版本比较；前一个版本获取失败时
+	// 第一个导出的版本在变更日志中标为基线
+	baseline := false
+	if firstRevision > 1 {
+		baseOpts := *opts
+		baseOpts.revision = firstRevision - 1
+		baseOpts.dryRun = true
+		baseOpts.textOnly = true
+		if base, err := renderDocument(ctx, client, url, &baseOpts); err == nil {
+			previous = base.body
+		} else {
+			fmt.Printf("  ⚠️  %v\n", err)
+			baseline = true
+		}
+	}
+	failed := 0
+	for revision := firstRevision; revision <= latestRevision; revision++ {
+		revOpts := *opts
+		revOpts.outputDir = historyDir
+		revOpts.assetsRoot = opts.outputDir
+		revOpts.revision = revision
+		revOpts.textOnly = true
+		doc, err := renderDocument(ctx, client, url, &revOpts)
+		if err != nil {
+			// 部分版本可能无法获取（例如权限不足），跳过但不中断导出
+			fmt.Printf("  ⚠️  %v\n", err)
+			failed++
+			continue
+		}
+		if doc.body == previous && !baseline {
+			continue
+		}
+		if err := writeDocument(doc, &revOpts); err != nil {
+			return err
+		}
+		revisions = append(revisions, core.RevisionChanges{
+			Revision: revision,
+			Title:    doc.docx.Title,
+			Link:     filepath.ToSlash(doc.mdName),
+			Changes:  core.DiffSections(previous, doc.body),
+			Baseline: baseline,
+		})
+		previous = doc.body
+		baseline = false
+	}
+	if total := latestRevision - firstRevision + 1; int64(failed) == total {
+		return fmt.Errorf("文档 %s 的 %d 个历史版本均获取失败", latest.docx.Title, total)
+	}
+
+	changelogPath := filepath.Join(historyDir, "CHANGELOG.md")
+	changelog := core.RenderChangelog(latest.docx.Title, revisions)
+	if err := os.MkdirAll(historyDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(changelogPath, []byte(changelog), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("共保存 %d 个有变化的版本，变更日志: %s\n", len(revisions), changelogPath)
+	return nil
+}
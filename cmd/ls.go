@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+	"github.com/urfave/cli/v2"
+)
+
+type LsOpts struct {
+	depth   int
+	objType string
+	json    bool
+}
+
+var lsOpts = LsOpts{}
+
+// lsEntry 是知识库节点或文件夹中的一个文件
+type lsEntry struct {
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	// Token 对知识库是节点 token，对文件夹是文件 token
+	Token    string `json:"token"`
+	ObjToken string `json:"obj_token,omitempty"`
+	EditTime string `json:"edit_time,omitempty"`
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`
+	Parent   string `json:"parent,omitempty"`
+}
+
+// getLsCommand returns the ls command definition
+func getLsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "ls",
+		Usage:     "List the tree of a wiki space, wiki page or folder",
+		ArgsUsage: "<wiki settings url | wiki page url | folder url>",
+		Description: "Prints one tab-separated line per node: indented title, type, token, " +
+			"last edit time and url. The output can be piped into 'feishu2md sync add -'.",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:        "depth",
+				Usage:       "Maximum depth to list, 0 for unlimited",
+				Destination: &lsOpts.depth,
+			},
+			&cli.StringFlag{
+				Name:        "type",
+				Usage:       "Only list nodes of the given type, e.g. docx, sheet, bitable, folder",
+				Destination: &lsOpts.objType,
+			},
+			&cli.BoolFlag{
+				Name:        "json",
+				Value:       false,
+				Usage:       "Print the nodes as a JSON array",
+				Destination: &lsOpts.json,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("Please specify the wiki or folder url", 1)
+			}
+			return handleLsCommand(ctx.Args().First())
+		},
+	}
+}
+
+func handleLsCommand(url string) error {
+	configPath, err := core.GetConfigFilePath()
+	if err != nil {
+		return err
+	}
+	config, err := core.ReadConfigFromFile(configPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Output.Validate(); err != nil {
+		return err
+	}
+	client := core.NewClient(config.Feishu.AppId, config.Feishu.AppSecret)
+	ctx := context.Background()
+
+	var root string
+	var entries []*lsEntry
+	switch {
+	case strings.Contains(url, "/wiki/settings/"):
+		prefixURL, spaceID, err := utils.ValidateWikiURL(url)
+		if err != nil {
+			return err
+		}
+		if root, err = client.GetWikiName(ctx, spaceID); err != nil {
+			return err
+		}
+		entries, err = listWikiNodes(ctx, client, prefixURL, spaceID, nil, 1)
+		if err != nil {
+			return err
+		}
+	case strings.Contains(url, "/wiki/"):
+		docType, nodeToken, err := utils.ValidateDocumentURL(url)
+		if err != nil || docType != "wiki" {
+			return fmt.Errorf("invalid wiki page url: %s", url)
+		}
+		node, err := client.GetWikiNodeInfo(ctx, nodeToken)
+		if err != nil {
+			return err
+		}
+		root = node.Title
+		entries, err = listWikiNodes(ctx, client, urlOrigin(url), node.SpaceID, &node.NodeToken, 1)
+		if err != nil {
+			return err
+		}
+	default:
+		folderToken, err := utils.ValidateFolderURL(url)
+		if err != nil {
+			return err
+		}
+		root = folderToken
+		entries, err = listFolderFiles(ctx, client, folderToken, 1)
+		if err != nil {
+			return err
+		}
+		if err := setFolderEditTimes(ctx, client, entries); err != nil {
+			return err
+		}
+	}
+
+	if lsOpts.objType != "" {
+		filtered := make([]*lsEntry, 0, len(entries))
+		for _, e := range entries {
+			if e.Type == lsOpts.objType {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	if lsOpts.json {
+		fmt.Println(utils.PrettyPrint(entries))
+		return nil
+	}
+	fmt.Printf("# %s\n", root)
+	for _, e := range entries {
+		fmt.Printf("%s%s\t%s\t%s\t%s\t%s\n",
+			strings.Repeat("  ", e.Depth-1), e.Title, e.Type, e.Token, e.EditTime, e.URL)
+	}
+	return nil
+}
+
+// listWikiNodes 按树的先序列出知识库节点的子孙节点
+func listWikiNodes(ctx context.Context, client *core.Client, prefixURL, spaceID string, parentNodeToken *string, depth int) ([]*lsEntry, error) {
+	nodes, err := client.GetWikiNodeList(ctx, spaceID, parentNodeToken)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*lsEntry, 0, len(nodes))
+	for _, n := range nodes {
+		entries = append(entries, &lsEntry{
+			Title:    n.Title,
+			Type:     n.ObjType,
+			Token:    n.NodeToken,
+			ObjToken: n.ObjToken,
+			EditTime: formatEditTime(parseUnixTime(n.ObjEditTime)),
+			URL:      prefixURL + "/wiki/" + n.NodeToken,
+			Depth:    depth,
+			Parent:   n.ParentNodeToken,
+		})
+		if n.HasChild && (lsOpts.depth == 0 || depth < lsOpts.depth) {
+			children, err := listWikiNodes(ctx, client, prefixURL, spaceID, &n.NodeToken, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, children...)
+		}
+	}
+	return entries, nil
+}
+
+// listFolderFiles 按树的先序列出文件夹中的文件和子文件夹
+func listFolderFiles(ctx context.Context, client *core.Client, folderToken string, depth int) ([]*lsEntry, error) {
+	files, err := client.GetDriveFolderFileList(ctx, nil, &folderToken)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*lsEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, &lsEntry{
+			Title:  f.Name,
+			Type:   f.Type,
+			Token:  f.Token,
+			URL:    f.URL,
+			Depth:  depth,
+			Parent: folderToken,
+		})
+		if f.Type == "folder" && (lsOpts.depth == 0 || depth < lsOpts.depth) {
+			children, err := listFolderFiles(ctx, client, f.Token, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, children...)
+		}
+	}
+	return entries, nil
+}
+
+// setFolderEditTimes 文件列表接口不返回编辑时间，批量查询文档元数据补上
+func setFolderEditTimes(ctx context.Context, client *core.Client, entries []*lsEntry) error {
+	docs := make([]*lark.GetDriveFileMetaReqRequestDocs, 0, len(entries))
+	byToken := make(map[string]*lsEntry)
+	for _, e := range entries {
+		if e.Type == "folder" || e.Type == "shortcut" {
+			continue
+		}
+		docs = append(docs, &lark.GetDriveFileMetaReqRequestDocs{DocToken: e.Token, DocType: e.Type})
+		byToken[e.Token] = e
+	}
+	metas, err := client.GetDriveFileMetaList(ctx, docs)
+	if err != nil {
+		return err
+	}
+	for _, m := range metas {
+		if e, ok := byToken[m.DocToken]; ok {
+			e.EditTime = formatEditTime(parseUnixTime(m.LatestModifyTime))
+		}
+	}
+	return nil
+}
+
+func formatEditTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// parseLsLine 解析 ls 的一行输出（制表符分隔或 JSON），也接受只有链接的行
+func parseLsLine(line string) (name, url string, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var e lsEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil || e.URL == "" {
+			return "", "", false
+		}
+		return e.Title, e.URL, true
+	}
+	cols := strings.Split(line, "\t")
+	url = strings.TrimSpace(cols[len(cols)-1])
+	if !strings.HasPrefix(url, "https://") {
+		return "", "", false
+	}
+	if len(cols) > 1 {
+		name = strings.TrimSpace(cols[0])
+	}
+	if name == "" {
+		// 只有链接时以链接中的 token 作为名称
+		name = url[strings.LastIndex(strings.TrimRight(url, "/"), "/")+1:]
+	}
+	return name, url, true
+}
+
+// addDocumentsFromStdin 把 ls 的输出（逐行或 JSON 数组）添加到同步配置中，已存在的文档跳过
+func addDocumentsFromStdin(config *SyncConfig, group string) (int, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return 0, err
+	}
+	added := 0
+	add := func(name, url string) {
+		if err := config.AddDocument(name, url, group); err != nil {
+			fmt.Printf("跳过 %s: %v\n", name, err)
+			return
+		}
+		fmt.Printf("Added document '%s' to sync configuration\n", name)
+		added++
+	}
+	// ls --json 输出的是 JSON 数组
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		var entries []*lsEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			add(e.Title, e.URL)
+		}
+		return added, nil
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		name, url, ok := parseLsLine(line)
+		if !ok {
+			continue
+		}
+		add(name, url)
+	}
+	return added, nil
+}
@@ -68,9 +68,55 @@ func main() {
 					&cli.BoolFlag{
 						Name:        "wiki",
 						Value:       false,
-						Usage:       "Download all documents within the wiki.",
+						Usage:       "Download all documents within the wiki space or below the wiki page.",
 						Destination: &dlOpts.wiki,
 					},
+					&cli.StringSliceFlag{
+						Name:  "include",
+						Usage: "With --wiki, only download pages whose title or path matches the glob, with their subtrees",
+					},
+					&cli.StringSliceFlag{
+						Name:  "exclude",
+						Usage: "With --wiki, skip pages whose title or path matches the glob, with their subtrees",
+					},
+					&cli.IntFlag{
+						Name:        "max-depth",
+						Usage:       "With --wiki, only download pages up to the given depth, 0 for unlimited",
+						Destination: &dlOpts.maxDepth,
+					},
+					&cli.StringFlag{
+						Name:        "site",
+						Usage:       "With --wiki, keep the wiki order as mkdocs, docusaurus or mdbook navigation, or numeric filename prefixes",
+						Destination: &dlOpts.site,
+					},
+					&cli.StringFlag{
+						Name:        "comments",
+						Usage:       "Export document comments as footnotes or appendix",
+						Destination: &dlOpts.comments,
+					},
+					&cli.BoolFlag{
+						Name:        "comments-json",
+						Value:       false,
+						Usage:       "Write document comments to a side-car <name>.comments.json file",
+						Destination: &dlOpts.commentsJSON,
+					},
+					&cli.Int64Flag{
+						Name:        "revision",
+						Usage:       "Download the given historical revision of the document",
+						Destination: &dlOpts.revision,
+					},
+					&cli.BoolFlag{
+						Name:        "history",
+						Value:       false,
+						Usage:       "Export a snapshot of every revision of the document and a changelog",
+						Destination: &dlOpts.history,
+					},
+					&cli.IntFlag{
+						Name:        "history-limit",
+						Value:       20,
+						Usage:       "With --history, only export the latest N revisions, 0 for all",
+						Destination: &dlOpts.historyLimit,
+					},
 				},
 				ArgsUsage: "<url>",
 				Action: func(ctx *cli.Context) error {
@@ -78,12 +124,17 @@ func main() {
 						return cli.Exit("Please specify the document/folder/wiki url", 1)
 					} else {
 						url := ctx.Args().First()
+						dlOpts.include = ctx.StringSlice("include")
+						dlOpts.exclude = ctx.StringSlice("exclude")
 						return handleDownloadCommand(url)
 					}
 				},
 			},
 			getSyncCommand(),
 			getMergeCommand(),
+			getDiffCommand(),
+			getUploadCommand(),
+			getLsCommand(),
 		},
 	}
 
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+)
+
+// nonDocxItem 是知识库或文件夹中不是新版文档的节点
+type nonDocxItem struct {
+	objType string
+	token   string // 电子表格、多维表格或文件的 token
+	title   string
+	name    string // 导出的文件名（不含扩展名）
+	url     string // 飞书中的链接
+}
+
+var nonDocxTypeNames = map[string]string{
+	"sheet":    "电子表格",
+	"bitable":  "多维表格",
+	"file":     "文件",
+	"mindnote": "思维笔记",
+	"doc":      "旧版文档",
+	"slides":   "幻灯片",
+	"shortcut": "快捷方式",
+}
+
+// nonDocxHandler 返回配置文件 non_docx 中该类型的处理方式，未配置的类型跳过
+func nonDocxHandler(objType string) string {
+	if handler, ok := dlConfig.Output.NonDocx[objType]; ok {
+		return handler
+	}
+	return "skip"
+}
+
+// exportNonDocx 按配置导出非新版文档：电子表格和多维表格导出为 CSV/XLSX，上传的文件原样下载。
+// 导出失败时不影响其它文档，改为生成占位文件
+func exportNonDocx(ctx context.Context, client *core.Client, item nonDocxItem, outputDir string) error {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return err
+	}
+	err := runNonDocxHandler(ctx, client, item, outputDir, nonDocxHandler(item.objType))
+	if err == nil {
+		return nil
+	}
+	fmt.Printf("  ⚠️  导出%s %s 失败，改为生成占位文件: %v\n", nonDocxTypeName(item.objType), item.title, err)
+	_, err = writeNonDocxStub(item, outputDir)
+	return err
+}
+
+func runNonDocxHandler(ctx context.Context, client *core.Client, item nonDocxItem, outputDir, handler string) error {
+	baseName := utils.SanitizeFileName(item.name)
+	switch {
+	case handler == "skip":
+		return nil
+	case handler == "stub":
+		_, err := writeNonDocxStub(item, outputDir)
+		return err
+	case handler == "xlsx" && (item.objType == "sheet" || item.objType == "bitable"):
+		path := filepath.Join(outputDir, baseName+".xlsx")
+		if err := client.ExportDriveFile(ctx, item.token, item.objType, "xlsx", path); err != nil {
+			return err
+		}
+		fmt.Printf("已导出 %s\n", path)
+		return nil
+	case handler == "csv" && item.objType == "sheet":
+		sheets, err := client.GetSheetValues(ctx, item.token)
+		if err != nil {
+			return err
+		}
+		for _, s := range sheets {
+			path := filepath.Join(outputDir, baseName+".csv")
+			if len(sheets) > 1 {
+				path = filepath.Join(outputDir, baseName+"_"+sanitizeFileName(s.Title)+".csv")
+			}
+			var headers []string
+			rows := s.Rows
+			if len(rows) > 0 {
+				headers, rows = rows[0], rows[1:]
+			}
+			if err := writeCSV(path, headers, rows); err != nil {
+				return err
+			}
+			fmt.Printf("Exported CSV to %s\n", path)
+		}
+		return nil
+	case handler == "csv" && item.objType == "bitable":
+		tables, err := client.GetBitableTableList(ctx, item.token)
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			headers, rows, _, err := loadBitableTable(ctx, client, item.token, t.TableID, "", true, false, false)
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(outputDir, baseName+".csv")
+			if len(tables) > 1 {
+				path = filepath.Join(outputDir, baseName+"_"+sanitizeFileName(t.Name)+".csv")
+			}
+			if err := writeCSV(path, headers, rows); err != nil {
+				return err
+			}
+			fmt.Printf("Exported CSV to %s\n", path)
+		}
+		return nil
+	case handler == "download" && item.objType == "file":
+		path, err := client.DownloadDriveFile(ctx, item.token, outputDir, item.name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已导出 %s\n", path)
+		return nil
+	}
+	return fmt.Errorf("unsupported non_docx handler %q for %s", handler, item.objType)
+}
+
+// writeNonDocxStub 生成指向飞书原文的占位 Markdown，返回其路径
+func writeNonDocxStub(item nonDocxItem, outputDir string) (string, error) {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return "", err
+	}
+	stub := fmt.Sprintf("# %s\n\n> 该页面是飞书%s，未导出为 Markdown，请[在飞书中查看](%s)。\n",
+		item.title, nonDocxTypeName(item.objType), item.url)
+	path := filepath.Join(outputDir, utils.SanitizeFileName(item.name)+".md")
+	if err := os.WriteFile(path, []byte(stub), 0o644); err != nil {
+		return "", err
+	}
+	fmt.Printf("已生成占位文件 %s\n", path)
+	return path, nil
+}
+
+func nonDocxTypeName(objType string) string {
+	if name, ok := nonDocxTypeNames[objType]; ok {
+		return name
+	}
+	return objType
+}
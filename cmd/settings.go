@@ -31,6 +31,12 @@ type SyncSettings struct {
 	BitableViewFieldsOnly bool `json:"bitable_view_fields_only" yaml:"bitable_view_fields_only"`
 	// 是否过滤图片引用：true 表示从表格导出中移除图片文件名，减少无用的文本噪音
 	FilterImageReferences bool `json:"filter_image_references" yaml:"filter_image_references"`
+	// 图片存放方式：per_doc 每个文档单独存放；shared 按内容哈希存放到公共 assets 目录并去重
+	// 为空时使用 config.json 中的 assets_layout
+	AssetsLayout string `json:"assets_layout,omitempty" yaml:"assets_layout,omitempty"`
+	// 同步方向：pull（默认）只下载；push 只把本地修改推送到飞书；bidirectional 双向同步。
+	// push/bidirectional 下两边都有修改时写出冲突文件而不覆盖，也不会清理输出目录
+	Direction string `json:"direction,omitempty" yaml:"direction,omitempty"`
 }
 
 // MergeSettings represents merge-specific settings
@@ -63,6 +69,16 @@ type DocConfig struct {
 	BitableViewFieldsOnly *bool `json:"bitable_view_fields_only,omitempty" yaml:"bitable_view_fields_only,omitempty"`
 	// 针对单个文档覆盖：是否过滤图片引用
 	FilterImageReferences *bool `json:"filter_image_references,omitempty" yaml:"filter_image_references,omitempty"`
+	// 针对单个文档覆盖：内嵌多维表格最多渲染的行数，0 表示不限制
+	BitableMaxRows *int `json:"bitable_max_rows,omitempty" yaml:"bitable_max_rows,omitempty"`
+	// 针对单个文档追加到 front matter 的静态字段，如 tags、weight；与元数据字段同名时覆盖
+	FrontMatter map[string]interface{} `json:"front_matter,omitempty" yaml:"front_matter,omitempty"`
+	// 知识库空间：只下载标题或路径匹配的节点及其子树、跳过匹配的子树、限制下载层级
+	Include  []string `json:"include,omitempty" yaml:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	MaxDepth int      `json:"max_depth,omitempty" yaml:"max_depth,omitempty"`
+	// 知识库空间：按知识库中的顺序生成导航，mkdocs、docusaurus、mdbook 或 prefix（文件名序号前缀）
+	Site string `json:"site,omitempty" yaml:"site,omitempty"`
 }
 
 // NewSyncConfig creates a new sync configuration with defaults
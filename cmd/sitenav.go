@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validSite 检查 --site 的取值
+func validSite(site string) error {
+	switch site {
+	case "", "mkdocs", "docusaurus", "mdbook", "prefix":
+		return nil
+	}
+	return fmt.Errorf("unknown site %q, expected mkdocs, docusaurus, mdbook or prefix", site)
+}
+
+// writeSiteNav 按知识库中的顺序为静态站点生成导航文件，prefix 在下载时已经写入文件名
+func writeSiteNav(site string, root *wikiNavEntry) error {
+	if site == "" || site == "prefix" || len(root.children) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(root.folder, 0o755); err != nil {
+		return err
+	}
+	switch site {
+	case "mkdocs":
+		return writeMkDocsNav(root)
+	case "docusaurus":
+		if err := writeDocusaurusNav(root.children); err != nil {
+			return err
+		}
+		fmt.Printf("已生成 Docusaurus 导航 %s\n", root.folder)
+	case "mdbook":
+		return writeMdBookSummary(root)
+	}
+	return nil
+}
+
+// navLink 返回导航中相对于站点根目录的链接，根目录之外或没有导出内容时返回空字符串
+func navLink(rootDir, path string) string {
+	if path == "" {
+		return ""
+	}
+	link := assetLink(rootDir, path)
+	if strings.HasPrefix(link, "../") {
+		return ""
+	}
+	return link
+}
+
+// writeMkDocsNav 生成 mkdocs.nav.yml，可以在 mkdocs.yml 中通过 INHERIT 引用
+func writeMkDocsNav(root *wikiNavEntry) error {
+	nav := mkDocsNavItems(root.folder, root.children)
+	if link := navLink(root.folder, root.path); link != "" {
+		nav = append([]interface{}{link}, nav...)
+	}
+	data, err := yaml.Marshal(map[string]interface{}{"nav": nav})
+	if err != nil {
+		return err
+	}
+	outputPath := filepath.Join(root.folder, "mkdocs.nav.yml")
+	if err := os.WriteFile(outputPath, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("已生成 MkDocs 导航 %s\n", outputPath)
+	return nil
+}
+
+func mkDocsNavItems(rootDir string, entries []wikiNavEntry) []interface{} {
+	items := make([]interface{}, 0, len(entries))
+	for _, e := range entries {
+		link := navLink(rootDir, e.path)
+		if len(e.children) == 0 {
+			if link == "" {
+				link = e.url
+			}
+			items = append(items, map[string]interface{}{e.title: link})
+			continue
+		}
+		// 父页面自身的内容作为章节的第一页
+		section := make([]interface{}, 0, len(e.children)+1)
+		if link != "" {
+			section = append(section, link)
+		}
+		section = append(section, mkDocsNavItems(rootDir, e.children)...)
+		items = append(items, map[string]interface{}{e.title: section})
+	}
+	return items
+}
+
+// writeDocusaurusNav 为每个目录写 _category_.json，并在文档的 front matter 中写入 sidebar_position
+func writeDocusaurusNav(entries []wikiNavEntry) error {
+	for _, e := range entries {
+		if e.folder != "" {
+			category, _ := json.MarshalIndent(map[string]interface{}{
+				"label":    e.title,
+				"position": e.position,
+			}, "", "  ")
+			if err := os.WriteFile(filepath.Join(e.folder, "_category_.json"), category, 0o644); err != nil {
+				return err
+			}
+		}
+		if e.path != "" {
+			if err := setSidebarPosition(e.path, e.position); err != nil {
+				return err
+			}
+		}
+		if err := writeDocusaurusNav(e.children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSidebarPosition 把 sidebar_position 写入 markdown 的 front matter，没有 front matter 时新增
+func setSidebarPosition(path string, position int) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	markdown := string(data)
+	switch {
+	case strings.HasPrefix(markdown, "---\n"):
+		markdown = fmt.Sprintf("---\nsidebar_position: %d\n", position) + strings.TrimPrefix(markdown, "---\n")
+	case strings.HasPrefix(markdown, "+++\n"):
+		markdown = fmt.Sprintf("+++\nsidebar_position = %d\n", position) + strings.TrimPrefix(markdown, "+++\n")
+	default:
+		markdown = fmt.Sprintf("---\nsidebar_position: %d\n---\n\n", position) + markdown
+	}
+	return os.WriteFile(path, []byte(markdown), 0o644)
+}
+
+// writeMdBookSummary 生成 mdBook 的 SUMMARY.md，没有导出内容的节点写为草稿章节
+func writeMdBookSummary(root *wikiNavEntry) error {
+	buf := new(strings.Builder)
+	buf.WriteString("# Summary\n\n")
+	if link := navLink(root.folder, root.path); link != "" {
+		fmt.Fprintf(buf, "[%s](%s)\n\n", root.title, mdBookLink(link))
+	}
+	writeMdBookItems(buf, root.folder, root.children, 0)
+	outputPath := filepath.Join(root.folder, "SUMMARY.md")
+	if err := os.WriteFile(outputPath, []byte(buf.String()), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("已生成 mdBook 目录 %s\n", outputPath)
+	return nil
+}
+
+func writeMdBookItems(buf *strings.Builder, rootDir string, entries []wikiNavEntry, depth int) {
+	for _, e := range entries {
+		fmt.Fprintf(buf, "%s- [%s](%s)\n", strings.Repeat("  ", depth), e.title, mdBookLink(navLink(rootDir, e.path)))
+		writeMdBookItems(buf, rootDir, e.children, depth+1)
+	}
+}
+
+// mdBookLink 用尖括号包住含空格或括号的路径
+func mdBookLink(link string) string {
+	if strings.ContainsAny(link, " ()") {
+		return "<" + link + ">"
+	}
+	return link
+}
@@ -20,6 +20,8 @@ type SyncOpts struct {
 	configPath string
 	group      string
 	force      bool
+	forcePush  bool
+	direction  string
 }
 
 var syncOpts = SyncOpts{}
@@ -45,12 +47,13 @@ func getSyncCommand() *cli.Command {
 			{
 				Name:      "add",
 				Usage:     "Add a document to sync configuration",
-				ArgsUsage: "<url>",
+				ArgsUsage: "<url | ->",
+				Description: "With '-' as url, reads the documents to add from the output of " +
+					"'feishu2md ls' on stdin, named after their titles.",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "name",
-						Usage:    "Document name",
-						Required: true,
+						Name:  "name",
+						Usage: "Document name",
 					},
 					&cli.StringFlag{
 						Name:  "group",
@@ -96,6 +99,16 @@ func getSyncCommand() *cli.Command {
 						Usage:       "Force re-download all documents",
 						Destination: &syncOpts.force,
 					},
+					&cli.BoolFlag{
+						Name:        "force-push",
+						Usage:       "Push local changes even when the remote document has content Markdown can't keep",
+						Destination: &syncOpts.forcePush,
+					},
+					&cli.StringFlag{
+						Name:        "direction",
+						Usage:       "Override the sync direction: pull, push or bidirectional",
+						Destination: &syncOpts.direction,
+					},
 					&cli.StringFlag{
 						Name:        "config",
 						Usage:       "Path to config file",
@@ -160,12 +173,27 @@ func handleSyncAdd(ctx *cli.Context) error {
 	url := ctx.Args().First()
 	name := ctx.String("name")
 	group := ctx.String("group")
+	if url != "-" && name == "" {
+		return cli.Exit("Please specify document name with --name", 1)
+	}
 
 	config, err := LoadSyncConfig(syncOpts.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %v", err)
 	}
 
+	if url == "-" {
+		added, err := addDocumentsFromStdin(config, group)
+		if err != nil {
+			return err
+		}
+		if err := config.Save(syncOpts.configPath); err != nil {
+			return fmt.Errorf("failed to save config: %v", err)
+		}
+		fmt.Printf("Added %d documents to sync configuration\n", added)
+		return nil
+	}
+
 	if err := config.AddDocument(name, url, group); err != nil {
 		return err
 	}
@@ -259,6 +287,21 @@ func handleSyncRun(ctx *cli.Context) error {
 		return fmt.Errorf("failed to load feishu config: %v\nPlease run 'feishu2md config --appId <id> --appSecret <secret>' first", err)
 	}
 
+	if syncConfig.Sync.AssetsLayout != "" {
+		feishuConfig.Output.AssetsLayout = syncConfig.Sync.AssetsLayout
+	}
+	if syncOpts.direction != "" {
+		syncConfig.Sync.Direction = syncOpts.direction
+	}
+	switch syncConfig.Sync.Direction {
+	case "", "pull", "push", "bidirectional":
+	default:
+		return cli.Exit(fmt.Sprintf("unknown sync direction %q, expected pull, push or bidirectional", syncConfig.Sync.Direction), 1)
+	}
+	if err := feishuConfig.Output.Validate(); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
 	// Get documents to sync
 	documents := syncConfig.GetDocuments(syncOpts.group)
 	if len(documents) == 0 {
@@ -274,7 +317,10 @@ func handleSyncRun(ctx *cli.Context) error {
 	// 根据同步模式决定是否清理目录
 	// clean_all: 总是清理
 	// incremental: 不清理，但 --force 标志可以强制清理
-	if syncConfig.Sync.SyncMode == "clean_all" || syncOpts.force {
+	// 推送模式下本地文件可能有未推送的修改，不能清理
+	if isTwoWaySync(&syncConfig.Sync) {
+		fmt.Printf("Sync direction: %s\n", syncConfig.Sync.Direction)
+	} else if syncConfig.Sync.SyncMode == "clean_all" || syncOpts.force {
 		fmt.Println("Cleaning output directory...")
 		if err := cleanOutputDirectory(syncConfig.Sync.OutputDir); err != nil {
 			fmt.Printf("Warning: failed to clean output directory: %v\n", err)
@@ -300,6 +346,8 @@ func handleSyncRun(ctx *cli.Context) error {
 		fmt.Printf("Filtered %d documents, %d will be synced\n", len(documents), len(documentsToSync))
 	}
 
+	registerSyncedDocuments(documents, &syncConfig.Sync)
+
 	// Sync documents with concurrency control
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, syncConfig.Sync.ConcurrentDownloads)
@@ -346,6 +394,18 @@ func handleSyncRun(ctx *cli.Context) error {
 	}
 
 	wg.Wait()
+	dlUnsupported.print()
+
+	// 共享图片目录：清理不再被任何文档引用的图片
+	if feishuConfig.Output.AssetsLayout == "shared" {
+		assetsDir := filepath.Join(syncConfig.Sync.OutputDir, feishuConfig.Output.AssetsDir)
+		removed, err := utils.CollectUnreferencedAssets(assetsDir, syncConfig.Sync.OutputDir)
+		if err != nil {
+			fmt.Printf("Warning: failed to clean unreferenced assets: %v\n", err)
+		} else if len(removed) > 0 {
+			fmt.Printf("已清理 %d 个未被引用的图片\n", len(removed))
+		}
+	}
 
 	// Print summary
 	elapsed := time.Since(startTime)
@@ -400,45 +460,21 @@ func syncDocument(ctx context.Context, client *core.Client, doc DocConfig, outpu
 		}
 	}
 
-	// Determine type: explicit config overrides URL auto-detect
-	docType := doc.Type
-	if docType == "" {
-		docType = "docx"
-		if strings.Contains(doc.URL, "/wiki/") {
-			docType = "wiki"
-		} else if strings.Contains(doc.URL, "/folder/") {
-			docType = "folder"
-		}
-	}
-
-	// 判断是否跳过图片下载：单文档配置优先级高于全局配置
-	skipImages := syncSettings.SkipImages // 默认使用全局配置
-	if doc.SkipImages != nil {
-		skipImages = *doc.SkipImages // 如果单文档有设置，则使用单文档配置
-	}
-
-	// 决定是否使用原始标题名
-	var docName string
-	if syncSettings.UseOriginalTitle {
-		docName = "" // 空字符串表示使用原始标题
-	} else {
-		docName = doc.Name // 使用配置中的自定义名称
-	}
-
-	opts := DownloadOpts{
-		outputDir:        outputDir,
-		dump:             false,
-		batch:            docType == "folder",
-		wiki:             docType == "wiki_space",
-		docName:          docName, // 根据配置决定使用哪个名称
-		skipImages:       skipImages,
-		useOriginalTitle: syncSettings.UseOriginalTitle, // 传递新的配置选项
+	docType := syncDocType(doc)
+	if isTwoWaySync(syncSettings) && (docType == "docx" || docType == "wiki" || docType == "wiki_page") {
+		return syncDocumentTwoWay(ctx, client, doc, docType, outputDir, syncSettings)
 	}
+	opts := newSyncDownloadOpts(doc, docType, outputDir, syncSettings)
+	docName := opts.docName
 
 	switch docType {
 	case "wiki_space":
 		// For entire wiki spaces (with /wiki/settings/ URL)
-		return downloadWiki(ctx, client, doc.URL)
+		return downloadWiki(ctx, client, doc.URL, &utils.TreeFilter{
+			Include:  doc.Include,
+			Exclude:  doc.Exclude,
+			MaxDepth: doc.MaxDepth,
+		}, doc.Site)
 	case "wiki_page":
 		// For individual wiki pages, treat them as documents
 		actualFileName, err := downloadDocument(ctx, client, doc.URL, &opts)
@@ -506,6 +542,76 @@ func syncDocument(ctx context.Context, client *core.Client, doc DocConfig, outpu
 	}
 }
 
+// syncDocType returns the type of a configured document: explicit config
+// overrides URL auto-detect
+func syncDocType(doc DocConfig) string {
+	docType := doc.Type
+	if docType == "" {
+		docType = "docx"
+		if strings.Contains(doc.URL, "/wiki/") {
+			docType = "wiki"
+		} else if strings.Contains(doc.URL, "/folder/") {
+			docType = "folder"
+		}
+	}
+	return docType
+}
+
+// newSyncDownloadOpts builds the download options of a configured document
+func newSyncDownloadOpts(doc DocConfig, docType, outputDir string, syncSettings *SyncSettings) DownloadOpts {
+	// 判断是否跳过图片下载：单文档配置优先级高于全局配置
+	skipImages := syncSettings.SkipImages // 默认使用全局配置
+	if doc.SkipImages != nil {
+		skipImages = *doc.SkipImages // 如果单文档有设置，则使用单文档配置
+	}
+
+	// 决定是否使用原始标题名
+	var docName string
+	if syncSettings.UseOriginalTitle {
+		docName = "" // 空字符串表示使用原始标题
+	} else {
+		docName = doc.Name // 使用配置中的自定义名称
+	}
+
+	opts := DownloadOpts{
+		outputDir:        outputDir,
+		dump:             false,
+		batch:            docType == "folder",
+		wiki:             docType == "wiki_space",
+		docName:          docName, // 根据配置决定使用哪个名称
+		skipImages:       skipImages,
+		useOriginalTitle: syncSettings.UseOriginalTitle, // 传递新的配置选项
+		assetsRoot:       syncSettings.OutputDir,
+		group:            doc.Group,
+		frontMatter:      doc.FrontMatter,
+	}
+	opts.bitableMaxRows = doc.BitableMaxRows
+	return opts
+}
+
+// registerSyncedDocuments 在开始同步前登记文档的导出路径，同步块引用的源文档
+// 是否已导出不取决于同步顺序；使用原始标题或知识库页面的文件名和文档 ID 要等
+// 拉取后才知道，不登记，引用它们的同步块按内联处理
+func registerSyncedDocuments(documents []DocConfig, syncSettings *SyncSettings) {
+	if syncSettings.UseOriginalTitle {
+		return
+	}
+	for _, doc := range documents {
+		if syncDocType(doc) != "docx" || doc.Name == "" {
+			continue
+		}
+		docType, docToken, err := utils.ValidateDocumentURL(doc.URL)
+		if err != nil || docType != "docx" {
+			continue
+		}
+		outputDir := syncSettings.OutputDir
+		if syncSettings.OrganizeByGroup && doc.Group != "" {
+			outputDir = filepath.Join(outputDir, doc.Group)
+		}
+		dlExportedDocs.set(docToken, filepath.Join(outputDir, utils.SanitizeFileName(doc.Name)+".md"))
+	}
+}
+
 // cleanOutputDirectory removes all files in the output directory
 func cleanOutputDirectory(dir string) error {
 	if dir == "" || dir == "/" || dir == "." {
@@ -823,7 +929,8 @@ func checkTableDocumentExists(outputDir, url, docType string) (bool, error) {
 
 // 过滤需要同步的文档（用于增量模式）
 func filterDocumentsForSync(ctx context.Context, client *core.Client, documents []DocConfig, outputDir string, syncSettings *SyncSettings) ([]DocConfig, error) {
-	if syncSettings.SyncMode != "incremental" {
+	// 双向同步由 syncDocumentTwoWay 自行比较两边的修改
+	if syncSettings.SyncMode != "incremental" || isTwoWaySync(syncSettings) {
 		return documents, nil
 	}
 
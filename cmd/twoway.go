@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/chyroc/lark"
+)
+
+// syncState 是双向同步记录在 .feishu2md/<name>.meta 中的同步状态
+type syncState struct {
+	ActualFileName string
+	// RevisionID 是上次同步时远程文档的版本
+	RevisionID int64
+	// FileHash 是上次同步后本地文件内容的 sha256
+	FileHash string
+	// ConflictRevision 是写出冲突文件时远程文档的版本，冲突解决前不为 0
+	ConflictRevision int64
+}
+
+// isTwoWaySync 判断是否需要把本地修改推送到飞书
+func isTwoWaySync(syncSettings *SyncSettings) bool {
+	return syncSettings.Direction == "push" || syncSettings.Direction == "bidirectional"
+}
+
+func syncStatePath(doc DocConfig, outputDir string) string {
+	return filepath.Join(outputDir, ".feishu2md", fmt.Sprintf("%s.meta", utils.SanitizeFileName(doc.Name)))
+}
+
+// readSyncState 读取同步状态，没有双向同步记录（例如只由增量同步写入的元数据）时返回 nil
+func readSyncState(path string) *syncState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	state := &syncState{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "ActualFileName":
+			state.ActualFileName = value
+		case "RevisionID":
+			state.RevisionID, _ = strconv.ParseInt(value, 10, 64)
+		case "FileHash":
+			state.FileHash = value
+		case "ConflictRevision":
+			state.ConflictRevision, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	if state.FileHash == "" && state.ConflictRevision == 0 {
+		return nil
+	}
+	return state
+}
+
+func writeSyncState(path string, doc DocConfig, state *syncState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	metadata := fmt.Sprintf("URL=%s\nName=%s\nActualFileName=%s\nRevisionID=%d\nFileHash=%s\n",
+		doc.URL, doc.Name, state.ActualFileName, state.RevisionID, state.FileHash)
+	if state.ConflictRevision != 0 {
+		metadata += fmt.Sprintf("ConflictRevision=%d\n", state.ConflictRevision)
+	}
+	metadata += fmt.Sprintf("SyncTime=%s\n", time.Now().Format(time.RFC3339))
+	return os.WriteFile(path, []byte(metadata), 0o644)
+}
+
+func fileHash(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// resolveDocxToken 返回文档链接对应的 docx 文档 ID，知识库页面取其关联的文档
+func resolveDocxToken(ctx context.Context, client *core.Client, url string) (string, error) {
+	docType, docToken, err := utils.ValidateDocumentURL(url)
+	if err != nil {
+		return "", err
+	}
+	if docType == "wiki" {
+		node, err := client.GetWikiNodeInfo(ctx, docToken)
+		if err != nil {
+			return "", err
+		}
+		if node.ObjType != "docx" {
+			return "", fmt.Errorf("wiki page %s is a %s, only docx can be pushed", docToken, node.ObjType)
+		}
+		return node.ObjToken, nil
+	}
+	return docToken, nil
+}
+
+// syncDocumentTwoWay 按同步方向同步单个文档：比较远程版本与本地文件哈希判断哪一边有修改，
+// 只有本地修改时推送，只有远程修改时拉取，两边都有修改时写出冲突文件而不覆盖任何一边
+func syncDocumentTwoWay(ctx context.Context, client *core.Client, doc DocConfig, docType, outputDir string, syncSettings *SyncSettings) error {
+	docToken, err := resolveDocxToken(ctx, client, doc.URL)
+	if err != nil {
+		return err
+	}
+	docx, blocks, exts, err := client.GetDocxContentExt(ctx, docToken)
+	if err != nil {
+		return err
+	}
+
+	statePath := syncStatePath(doc, outputDir)
+	state := readSyncState(statePath)
+	localPath := syncedFilePath(doc, outputDir)
+	local, err := os.ReadFile(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	conflictPath := strings.TrimSuffix(localPath, ".md") + ".conflict.md"
+
+	sides := core.SyncSides{
+		RemoteRevision: docx.RevisionID,
+		LocalExists:    err == nil,
+		LocalHash:      fileHash(local),
+		PushOnly:       syncSettings.Direction == "push",
+	}
+	if state != nil {
+		sides.Synced = true
+		sides.RevisionID = state.RevisionID
+		sides.FileHash = state.FileHash
+		sides.ConflictRevision = state.ConflictRevision
+		if _, err := os.Stat(conflictPath); err == nil {
+			sides.ConflictFileExists = true
+		}
+	}
+
+	opts := newSyncDownloadOpts(doc, docType, outputDir, syncSettings)
+	switch core.TwoWaySyncAction(sides) {
+	case core.SyncConflictPending:
+		fmt.Printf("  ⚠️  冲突尚未解决，跳过: %s\n", conflictPath)
+		return nil
+	case core.SyncUpToDate:
+		fmt.Printf("  本地与远程均无变化: %s\n", doc.Name)
+		return nil
+	case core.SyncConflict:
+		remote, err := renderDocument(ctx, client, doc.URL, &opts)
+		if err != nil {
+			return err
+		}
+		newState := &syncState{ActualFileName: relativeSyncPath(outputDir, localPath)}
+		if state != nil {
+			*newState = *state
+		}
+		if remote.markdown == string(local) {
+			// 两边内容一致（例如首次双向同步），只记录同步状态
+			newState.RevisionID = remote.docx.RevisionID
+			newState.FileHash = fileHash(local)
+			newState.ConflictRevision = 0
+			return writeSyncState(statePath, doc, newState)
+		}
+		conflict := core.ConflictMarkers(string(local), remote.markdown,
+			"local", fmt.Sprintf("remote (revision %d)", remote.docx.RevisionID))
+		if err := os.WriteFile(conflictPath, []byte(conflict), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("  ⚠️  本地和远程都有修改，已写出冲突文件 %s，合并到 %s 并删除冲突文件后再次同步\n",
+			conflictPath, localPath)
+		newState.ConflictRevision = remote.docx.RevisionID
+		return writeSyncState(statePath, doc, newState)
+	case core.SyncPush:
+		if lost := core.UnpushableBlocks(blocks, exts); len(lost) > 0 && !syncOpts.forcePush {
+			return fmt.Errorf("远程文档 %s 有 %d 个块无法从 Markdown 还原（%s），推送会丢失这些内容；确认覆盖请使用 --force-push",
+				doc.Name, len(lost), describeBlocks(lost))
+		}
+		return pushSyncDocument(ctx, client, doc, docToken, outputDir, localPath, local)
+	case core.SyncSkipPull:
+		fmt.Printf("  远程文档有更新，push 模式下不拉取: %s\n", doc.Name)
+		return nil
+	}
+
+	remote, err := renderDocument(ctx, client, doc.URL, &opts)
+	if err != nil {
+		return err
+	}
+	if err := writeDocument(remote, &opts); err != nil {
+		return err
+	}
+	return writeSyncState(statePath, doc, &syncState{
+		ActualFileName: remote.mdName,
+		RevisionID:     remote.docx.RevisionID,
+		FileHash:       fileHash([]byte(remote.markdown)),
+	})
+}
+
+// describeBlocks 按块类型汇总块的数量，例如 "type=43 ×2, type=18 ×1"
+func describeBlocks(blocks []core.UnsupportedBlock) string {
+	counts := make(map[lark.DocxBlockType]int)
+	order := make([]lark.DocxBlockType, 0)
+	for _, b := range blocks {
+		if counts[b.BlockType] == 0 {
+			order = append(order, b.BlockType)
+		}
+		counts[b.BlockType]++
+	}
+	parts := make([]string, 0, len(order))
+	for _, t := range order {
+		parts = append(parts, fmt.Sprintf("type=%d ×%d", t, counts[t]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pushSyncDocument 用本地文件替换远程文档的内容，并记录推送后的版本。
+// 导出时添加的目录、锚点、评论脚注等内容在推送前去掉，避免写回为正文
+func pushSyncDocument(ctx context.Context, client *core.Client, doc DocConfig, docToken, outputDir, localPath string, local []byte) error {
+	markdown := core.StripExportSyntax(string(local), dlConfig.Output)
+	title, blocks := core.MarkdownToDocxBlocks(markdown, !dlConfig.Output.OmitTitle)
+	if err := core.LoadUploadImages(blocks, uploadImageLoader(filepath.Dir(localPath))); err != nil {
+		return err
+	}
+	if err := client.ReplaceDocxContent(ctx, docToken, title, blocks); err != nil {
+		return err
+	}
+	docx, _, err := client.GetDocxContent(ctx, docToken)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("  已推送本地修改 %s 到 %s\n", localPath, doc.URL)
+	return writeSyncState(syncStatePath(doc, outputDir), doc, &syncState{
+		ActualFileName: relativeSyncPath(outputDir, localPath),
+		RevisionID:     docx.RevisionID,
+		FileHash:       fileHash(local),
+	})
+}
+
+func relativeSyncPath(outputDir, path string) string {
+	if rel, err := filepath.Rel(outputDir, path); err == nil {
+		return rel
+	}
+	return filepath.Base(path)
+}
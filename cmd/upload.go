@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/urfave/cli/v2"
+)
+
+type UploadOpts struct {
+	folder     string
+	wikiParent string
+	title      string
+	createNew  bool
+}
+
+var uploadOpts = UploadOpts{}
+
+// uploadRecord 记录本地文件对应的飞书文档，保存在文件所在目录的 .feishu2md/<文件名>.upload 中
+type uploadRecord struct {
+	DocumentID string
+	URL        string
+}
+
+// getUploadCommand returns the upload command definition
+func getUploadCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "upload",
+		Usage:     "Publish a local markdown file as a feishu/larksuite docx document",
+		ArgsUsage: "<file.md>",
+		Description: "The first upload creates the document in --folder or below --wiki-parent; " +
+			"later uploads of the same file replace the content of that document.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "folder",
+				Usage:       "Create the document in the given folder url",
+				Destination: &uploadOpts.folder,
+			},
+			&cli.StringFlag{
+				Name:        "wiki-parent",
+				Usage:       "Create the document as a child of the given wiki page url",
+				Destination: &uploadOpts.wikiParent,
+			},
+			&cli.StringFlag{
+				Name:        "title",
+				Usage:       "Document title, defaults to the front matter title, the leading heading or the file name",
+				Destination: &uploadOpts.title,
+			},
+			&cli.BoolFlag{
+				Name:        "new",
+				Value:       false,
+				Usage:       "Create a new document even if the file was uploaded before",
+				Destination: &uploadOpts.createNew,
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() == 0 {
+				return cli.Exit("Please specify the markdown file", 1)
+			}
+			return handleUploadCommand(ctx.Args().First())
+		},
+	}
+}
+
+func handleUploadCommand(path string) error {
+	configPath, err := core.GetConfigFilePath()
+	if err != nil {
+		return err
+	}
+	config, err := core.ReadConfigFromFile(configPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Output.Validate(); err != nil {
+		return err
+	}
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	title, blocks := core.MarkdownToDocxBlocks(string(markdown), true)
+	if uploadOpts.title != "" {
+		title = uploadOpts.title
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	// 先读取全部图片，避免文档改到一半才发现图片缺失
+	if err := core.LoadUploadImages(blocks, uploadImageLoader(filepath.Dir(path))); err != nil {
+		return err
+	}
+
+	client := core.NewClient(config.Feishu.AppId, config.Feishu.AppSecret)
+	ctx := context.Background()
+
+	record, err := readUploadRecord(path)
+	if err != nil {
+		return err
+	}
+	if record == nil || uploadOpts.createNew {
+		record, err = createUploadDocument(ctx, client, title)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已创建文档 %s\n", record.URL)
+	} else {
+		fmt.Printf("更新已上传的文档 %s\n", record.URL)
+	}
+	// 先记录对应关系，上传中途失败时重试会更新同一个文档
+	if err := writeUploadRecord(path, record); err != nil {
+		return err
+	}
+
+	if err := client.ReplaceDocxContent(ctx, record.DocumentID, title, blocks); err != nil {
+		return err
+	}
+	fmt.Printf("已上传 %s -> %s\n", path, record.URL)
+	return nil
+}
+
+// createUploadDocument 按 --folder 或 --wiki-parent 创建空文档
+func createUploadDocument(ctx context.Context, client *core.Client, title string) (*uploadRecord, error) {
+	switch {
+	case uploadOpts.wikiParent != "":
+		docType, parentToken, err := utils.ValidateDocumentURL(uploadOpts.wikiParent)
+		if err != nil || docType != "wiki" {
+			return nil, fmt.Errorf("invalid wiki page url: %s", uploadOpts.wikiParent)
+		}
+		documentID, nodeToken, err := client.CreateWikiDocx(ctx, parentToken, title)
+		if err != nil {
+			return nil, err
+		}
+		return &uploadRecord{
+			DocumentID: documentID,
+			URL:        fmt.Sprintf("%s/wiki/%s", urlOrigin(uploadOpts.wikiParent), nodeToken),
+		}, nil
+	case uploadOpts.folder != "":
+		folderToken, err := utils.ValidateFolderURL(uploadOpts.folder)
+		if err != nil {
+			return nil, err
+		}
+		documentID, err := client.CreateDocx(ctx, folderToken, title)
+		if err != nil {
+			return nil, err
+		}
+		return &uploadRecord{
+			DocumentID: documentID,
+			URL:        fmt.Sprintf("%s/docx/%s", urlOrigin(uploadOpts.folder), documentID),
+		}, nil
+	}
+	return nil, fmt.Errorf("please specify --folder or --wiki-parent for the first upload")
+}
+
+var urlOriginRegexp = regexp.MustCompile(`^https://[\w-.]+`)
+
+func urlOrigin(url string) string {
+	return urlOriginRegexp.FindString(url)
+}
+
+// uploadImageLoader 读取 markdown 中引用的图片：网络图片直接下载，data URI 直接解码，
+// 本地图片相对于 markdown 文件所在目录
+func uploadImageLoader(baseDir string) func(src string) (string, []byte, error) {
+	return func(src string) (string, []byte, error) {
+		if strings.HasPrefix(src, "data:") {
+			header, payload, ok := strings.Cut(strings.TrimPrefix(src, "data:"), ",")
+			if !ok || !strings.HasSuffix(header, ";base64") {
+				return "", nil, fmt.Errorf("unsupported data URI")
+			}
+			data, err := base64.StdEncoding.DecodeString(payload)
+			mimeType := strings.TrimSuffix(header, ";base64")
+			return "image." + strings.TrimPrefix(mimeType, "image/"), data, err
+		}
+		if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+			resp, err := http.Get(src)
+			if err != nil {
+				return "", nil, err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", nil, fmt.Errorf("unexpected status %s", resp.Status)
+			}
+			data, err := io.ReadAll(resp.Body)
+			name := filepath.Base(strings.SplitN(src, "?", 2)[0])
+			return name, data, err
+		}
+		path := utils.UnescapeURL(src)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		return filepath.Base(path), data, err
+	}
+}
+
+func uploadRecordPath(path string) string {
+	return filepath.Join(filepath.Dir(path), ".feishu2md", filepath.Base(path)+".upload")
+}
+
+func readUploadRecord(path string) (*uploadRecord, error) {
+	data, err := os.ReadFile(uploadRecordPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &uploadRecord{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "DocumentID=") {
+			record.DocumentID = strings.TrimPrefix(line, "DocumentID=")
+		} else if strings.HasPrefix(line, "URL=") {
+			record.URL = strings.TrimPrefix(line, "URL=")
+		}
+	}
+	if record.DocumentID == "" {
+		return nil, nil
+	}
+	return record, nil
+}
+
+func writeUploadRecord(path string, record *uploadRecord) error {
+	recordPath := uploadRecordPath(path)
+	if err := os.MkdirAll(filepath.Dir(recordPath), 0o755); err != nil {
+		return err
+	}
+	data := fmt.Sprintf("DocumentID=%s\nURL=%s\nUploadTime=%s\n",
+		record.DocumentID, record.URL, time.Now().Format(time.RFC3339))
+	return os.WriteFile(recordPath, []byte(data), 0o644)
+}
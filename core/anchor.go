@@ -0,0 +1,103 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Heading is a heading of the parsed document with its anchor.
+type Heading struct {
+	Level  int
+	Text   string
+	Anchor string
+}
+
+// Slugify turns heading text into an anchor. The "github" style matches the
+// IDs GitHub and Hugo's default renderer generate, keeping CJK characters;
+// "hugo" matches Hugo's blackfriday style, collapsing every run of other
+// characters to a single dash; "pinyin" transliterates Chinese characters so
+// anchors stay ASCII.
+func Slugify(text, style string) string {
+	switch style {
+	case "hugo":
+		return blackfridaySlug(text)
+	case "pinyin":
+		return pinyinSlug(text)
+	}
+	return githubSlug(text)
+}
+
+func githubSlug(text string) string {
+	buf := new(strings.Builder)
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_':
+			buf.WriteRune(r)
+		case r == ' ':
+			buf.WriteRune('-')
+		}
+	}
+	return buf.String()
+}
+
+func blackfridaySlug(text string) string {
+	buf := new(strings.Builder)
+	dash := false
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			if dash && buf.Len() > 0 {
+				buf.WriteRune('-')
+			}
+			dash = false
+			buf.WriteRune(unicode.ToLower(r))
+		} else {
+			dash = true
+		}
+	}
+	return buf.String()
+}
+
+var pinyinArgs = pinyin.NewArgs()
+
+func pinyinSlug(text string) string {
+	buf := new(strings.Builder)
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			if pys := pinyin.SinglePinyin(r, pinyinArgs); len(pys) > 0 {
+				buf.WriteString(" " + pys[0] + " ")
+				continue
+			}
+		}
+		if r < unicode.MaxASCII {
+			buf.WriteRune(r)
+		} else {
+			buf.WriteRune(' ')
+		}
+	}
+	return blackfridaySlug(buf.String())
+}
+
+// anchorSet hands out unique anchors the way GitHub does, suffixing repeats
+// with -1, -2 and so on.
+type anchorSet map[string]int
+
+func (s anchorSet) unique(slug string) string {
+	if slug == "" {
+		slug = "section"
+	}
+	if _, used := s[slug]; !used {
+		s[slug] = 0
+		return slug
+	}
+	for {
+		s[slug]++
+		anchor := fmt.Sprintf("%s-%d", slug, s[slug])
+		if _, used := s[anchor]; !used {
+			s[anchor] = 0
+			return anchor
+		}
+	}
+}
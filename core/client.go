@@ -3,30 +3,70 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/Wsine/feishu2md/utils"
 	"github.com/chyroc/lark"
 	"github.com/chyroc/lark_rate_limiter"
 )
 
+const defaultOpenBaseURL = "https://open.feishu.cn"
+
 type Client struct {
-	larkClient *lark.Lark
+	larkClient  *lark.Lark
+	openBaseURL string
 }
 
 func NewClient(appID, appSecret string) *Client {
+	return NewClientWithBaseURL(appID, appSecret, defaultOpenBaseURL)
+}
+
+// NewClientWithBaseURL is NewClient for another address of the open
+// platform, such as a Lark deployment or a fake server in tests.
+func NewClientWithBaseURL(appID, appSecret, baseURL string) *Client {
 	return &Client{
 		larkClient: lark.New(
 			lark.WithAppCredential(appID, appSecret),
 			lark.WithTimeout(60*time.Second),
 			lark.WithApiMiddleware(lark_rate_limiter.Wait(4, 4)),
+			lark.WithOpenBaseURL(baseURL),
 		),
+		openBaseURL: baseURL,
 	}
 }
 
+// rawFileResp receives binary responses from RawRequest; JSON error bodies
+// still land in Code and Msg.
+type rawFileResp struct {
+	Code     int64  `json:"code,omitempty"`
+	Msg      string `json:"msg,omitempty"`
+	File     io.Reader
+	Filename string
+}
+
+func (r *rawFileResp) SetReader(file io.Reader) {
+	r.File = file
+}
+
+func (r *rawFileResp) SetFilename(filename string) {
+	r.Filename = filename
+}
+
+type rawBlockListResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data *struct {
+		Items     []json.RawMessage `json:"items,omitempty"`
+		PageToken string            `json:"page_token,omitempty"`
+		HasMore   bool              `json:"has_more,omitempty"`
+	} `json:"data,omitempty"`
+}
+
 func (c *Client) DownloadImage(ctx context.Context, imgToken, outDir string) (string, error) {
 	resp, _, err := c.larkClient.Drive.DownloadDriveMedia(ctx, &lark.DownloadDriveMediaReq{
 		FileToken: imgToken,
@@ -67,34 +107,119 @@ func (c *Client) DownloadImageRaw(ctx context.Context, imgToken, imgDir string)
 }
 
 func (c *Client) GetDocxContent(ctx context.Context, docToken string) (*lark.DocxDocument, []*lark.DocxBlock, error) {
+	docx, blocks, _, err := c.GetDocxContentExt(ctx, docToken)
+	return docx, blocks, err
+}
+
+// GetDocxContentExt is GetDocxContent that also returns the block payloads
+// the lark SDK does not model, keyed by block ID.
+func (c *Client) GetDocxContentExt(ctx context.Context, docToken string) (*lark.DocxDocument, []*lark.DocxBlock, map[string]*DocxBlockExt, error) {
+	return c.GetDocxContentAtRevision(ctx, docToken, 0)
+}
+
+// GetDocxContentAtRevision is GetDocxContentExt for a historical revision of
+// the document, 0 meaning the latest. Reading past revisions requires edit
+// permission on the document.
+func (c *Client) GetDocxContentAtRevision(ctx context.Context, docToken string, revision int64) (*lark.DocxDocument, []*lark.DocxBlock, map[string]*DocxBlockExt, error) {
 	resp, _, err := c.larkClient.Drive.GetDocxDocument(ctx, &lark.GetDocxDocumentReq{
 		DocumentID: docToken,
 	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	docx := &lark.DocxDocument{
 		DocumentID: resp.Document.DocumentID,
 		RevisionID: resp.Document.RevisionID,
 		Title:      resp.Document.Title,
 	}
-	var blocks []*lark.DocxBlock
+	var revisionID *int64
+	if revision > 0 {
+		if revision > docx.RevisionID {
+			return docx, nil, nil, fmt.Errorf("revision %d is newer than the latest revision %d", revision, docx.RevisionID)
+		}
+		revisionID = &revision
+		docx.RevisionID = revision
+	}
+	var items []json.RawMessage
 	var pageToken *string
 	for {
-		resp2, _, err := c.larkClient.Drive.GetDocxBlockListOfDocument(ctx, &lark.GetDocxBlockListOfDocumentReq{
-			DocumentID: docx.DocumentID,
-			PageToken:  pageToken,
-		})
+		resp2 := new(rawBlockListResp)
+		_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:  "Drive",
+			API:    "GetDocxBlockListOfDocument",
+			Method: "GET",
+			URL:    c.openBaseURL + "/open-apis/docx/v1/documents/:document_id/blocks",
+			Body: &lark.GetDocxBlockListOfDocumentReq{
+				DocumentID:         docx.DocumentID,
+				PageToken:          pageToken,
+				DocumentRevisionID: revisionID,
+			},
+			NeedTenantAccessToken: true,
+		}, resp2)
 		if err != nil {
-			return docx, nil, err
+			return docx, nil, nil, err
+		}
+		if resp2.Data == nil {
+			break
 		}
-		blocks = append(blocks, resp2.Items...)
-		pageToken = &resp2.PageToken
-		if !resp2.HasMore {
+		items = append(items, resp2.Data.Items...)
+		pageToken = &resp2.Data.PageToken
+		if !resp2.Data.HasMore {
 			break
 		}
 	}
-	return docx, blocks, nil
+	blocks, exts, err := decodeDocxBlocks(items)
+	if err != nil {
+		return docx, nil, nil, err
+	}
+	if revisionID != nil {
+		// The document meta is always the latest; take the title of the revision
+		for _, b := range blocks {
+			if b.BlockID == docx.DocumentID && b.Page != nil {
+				docx.Title = plainText(b.Page)
+			}
+		}
+	}
+	return docx, blocks, exts, nil
+}
+
+// DownloadBoardImage exports a whiteboard as a PNG image into outDir.
+func (c *Client) DownloadBoardImage(ctx context.Context, boardToken, outDir string) (string, error) {
+	filename, raw, err := c.DownloadBoardImageRaw(ctx, boardToken, outDir)
+	if err != nil {
+		return boardToken, err
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return boardToken, err
+	}
+	if err = os.WriteFile(filename, raw, 0o666); err != nil {
+		return boardToken, err
+	}
+	return filename, nil
+}
+
+func (c *Client) DownloadBoardImageRaw(ctx context.Context, boardToken, imgDir string) (string, []byte, error) {
+	resp := new(rawFileResp)
+	_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+		Scope:                 "Board",
+		API:                   "DownloadWhiteboardAsImage",
+		Method:                "GET",
+		URL:                   c.openBaseURL + "/open-apis/board/v1/whiteboards/" + boardToken + "/download_as_image",
+		Body:                  struct{}{},
+		NeedTenantAccessToken: true,
+	}, resp)
+	if err != nil {
+		return boardToken, nil, err
+	}
+	if resp.File == nil {
+		return boardToken, nil, fmt.Errorf("empty whiteboard image for %s", boardToken)
+	}
+	filename := fmt.Sprintf("%s/%s.png", imgDir, boardToken)
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(resp.File); err != nil {
+		return boardToken, nil, err
+	}
+	return filename, buf.Bytes(), nil
 }
 
 func (c *Client) GetWikiNodeInfo(ctx context.Context, token string) (*lark.GetWikiNodeRespNode, error) {
@@ -107,6 +232,332 @@ func (c *Client) GetWikiNodeInfo(ctx context.Context, token string) (*lark.GetWi
 	return resp.Node, nil
 }
 
+// GetWikiNodePath returns the titles of the ancestors of a wiki node, from
+// the top of the space down to its parent.
+func (c *Client) GetWikiNodePath(ctx context.Context, node *lark.GetWikiNodeRespNode) ([]string, error) {
+	path := make([]string, 0)
+	for parent := node.ParentNodeToken; parent != ""; {
+		n, err := c.GetWikiNodeInfo(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		path = append([]string{n.Title}, path...)
+		parent = n.ParentNodeToken
+	}
+	return path, nil
+}
+
+// GetDriveFileMeta returns the owner and timestamps of a document.
+func (c *Client) GetDriveFileMeta(ctx context.Context, docToken, docType string) (*lark.GetDriveFileMetaRespMeta, error) {
+	resp, _, err := c.larkClient.Drive.GetDriveFileMeta(ctx, &lark.GetDriveFileMetaReq{
+		RequestDocs: []*lark.GetDriveFileMetaReqRequestDocs{
+			{DocToken: docToken, DocType: docType},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Metas) == 0 {
+		return nil, fmt.Errorf("no meta returned for %s", docToken)
+	}
+	return resp.Metas[0], nil
+}
+
+// GetDriveFileMetaList returns the metas of many documents, querying them in
+// batches of 200 as the API allows. Documents without access are left out.
+func (c *Client) GetDriveFileMetaList(ctx context.Context, docs []*lark.GetDriveFileMetaReqRequestDocs) ([]*lark.GetDriveFileMetaRespMeta, error) {
+	metas := make([]*lark.GetDriveFileMetaRespMeta, 0, len(docs))
+	for start := 0; start < len(docs); start += 200 {
+		resp, _, err := c.larkClient.Drive.GetDriveFileMeta(ctx, &lark.GetDriveFileMetaReq{
+			RequestDocs: docs[start:min(start+200, len(docs))],
+		})
+		if err != nil {
+			return nil, err
+		}
+		metas = append(metas, resp.Metas...)
+	}
+	return metas, nil
+}
+
+// GetDocxComments returns the whole-document and inline comments of a docx
+// document, solved ones included, with replies in creation order.
+func (c *Client) GetDocxComments(ctx context.Context, docToken string) ([]*Comment, error) {
+	comments := make([]*Comment, 0)
+	var pageToken *string
+	for {
+		resp := new(rawCommentListResp)
+		_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:  "Drive",
+			API:    "GetDriveCommentList",
+			Method: "GET",
+			URL:    c.openBaseURL + "/open-apis/drive/v1/files/:file_token/comments",
+			Body: &lark.GetDriveCommentListReq{
+				FileToken: docToken,
+				FileType:  lark.FileTypeDocx,
+				PageToken: pageToken,
+			},
+			NeedTenantAccessToken: true,
+		}, resp)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Data == nil {
+			break
+		}
+		for _, item := range resp.Data.Items {
+			comment := &Comment{
+				ID:      item.CommentID,
+				Whole:   item.IsWhole,
+				Solved:  item.IsSolved,
+				Quote:   item.Quote,
+				Replies: make([]*CommentReply, 0),
+			}
+			if item.ReplyList != nil {
+				for _, r := range item.ReplyList.Replies {
+					reply := &CommentReply{
+						AuthorID:    r.UserID,
+						CreatedTime: time.Unix(r.CreateTime, 0),
+					}
+					if r.Content != nil {
+						reply.elements = r.Content.Elements
+					}
+					comment.Replies = append(comment.Replies, reply)
+				}
+			}
+			comments = append(comments, comment)
+		}
+		pageToken = &resp.Data.PageToken
+		if !resp.Data.HasMore {
+			break
+		}
+	}
+	SetCommentUserNames(comments, nil)
+	return comments, nil
+}
+
+type rawCreateDocxBlockReq struct {
+	DocumentID string            `path:"document_id" json:"-"`
+	BlockID    string            `path:"block_id" json:"-"`
+	Children   []json.RawMessage `json:"children"`
+}
+
+type rawCreateDocxBlockResp struct {
+	Code int64                     `json:"code,omitempty"`
+	Msg  string                    `json:"msg,omitempty"`
+	Data *lark.CreateDocxBlockResp `json:"data,omitempty"`
+}
+
+// rawDeleteDocxBlockReq keeps start_index 0, which the SDK request omits.
+type rawDeleteDocxBlockReq struct {
+	DocumentID string `path:"document_id" json:"-"`
+	BlockID    string `path:"block_id" json:"-"`
+	StartIndex int64  `json:"start_index"`
+	EndIndex   int64  `json:"end_index"`
+}
+
+type rawDocxResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+}
+
+// CreateDocx creates an empty docx document in a folder, the root folder
+// when folderToken is empty, and returns its document ID.
+func (c *Client) CreateDocx(ctx context.Context, folderToken, title string) (string, error) {
+	req := &lark.CreateDocxReq{Title: &title}
+	if folderToken != "" {
+		req.FolderToken = &folderToken
+	}
+	resp, _, err := c.larkClient.Drive.CreateDocx(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return resp.Document.DocumentID, nil
+}
+
+// CreateWikiDocx creates an empty docx document as a child of a wiki node
+// and returns its document ID and node token.
+func (c *Client) CreateWikiDocx(ctx context.Context, parentNodeToken, title string) (string, string, error) {
+	parent, err := c.GetWikiNodeInfo(ctx, parentNodeToken)
+	if err != nil {
+		return "", "", err
+	}
+	resp, _, err := c.larkClient.Drive.CreateWikiNode(ctx, &lark.CreateWikiNodeReq{
+		SpaceID:         parent.SpaceID,
+		ObjType:         "docx",
+		ParentNodeToken: &parentNodeToken,
+		NodeType:        "origin",
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return resp.Node.ObjToken, resp.Node.NodeToken, nil
+}
+
+// ReplaceDocxContent replaces all blocks of a docx document with blocks,
+// uploading their images, and sets its title unless title is empty. The new
+// blocks are created after the old ones, which are only deleted once all of
+// them exist; on failure the blocks created so far are removed again, so the
+// document keeps its old content.
+func (c *Client) ReplaceDocxContent(ctx context.Context, documentID, title string, blocks []*UploadBlock) error {
+	page, err := c.getDocxPageBlock(ctx, documentID)
+	if err != nil {
+		return err
+	}
+	old := int64(len(page.Children))
+	if err := c.createDocxBlocks(ctx, documentID, documentID, blocks); err != nil {
+		if page, perr := c.getDocxPageBlock(ctx, documentID); perr == nil && int64(len(page.Children)) > old {
+			if derr := c.deleteDocxChildren(ctx, documentID, documentID, old, int64(len(page.Children))); derr != nil {
+				return fmt.Errorf("%w (rolling back the created blocks also failed: %v)", err, derr)
+			}
+		}
+		return err
+	}
+	if old > 0 {
+		if err := c.deleteDocxChildren(ctx, documentID, documentID, 0, old); err != nil {
+			return err
+		}
+	}
+	if title != "" {
+		_, _, err := c.larkClient.Drive.UpdateDocxBlock(ctx, &lark.UpdateDocxBlockReq{
+			DocumentID: documentID,
+			BlockID:    documentID,
+			UpdateTextElements: &lark.UpdateDocxBlockReqUpdateTextElements{
+				Elements: []*lark.DocxTextElement{textRun(title, lark.DocxTextElementStyle{})},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) getDocxPageBlock(ctx context.Context, documentID string) (*lark.DocxBlock, error) {
+	resp, _, err := c.larkClient.Drive.GetDocxBlock(ctx, &lark.GetDocxBlockReq{
+		DocumentID: documentID,
+		BlockID:    documentID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Block, nil
+}
+
+func (c *Client) deleteDocxChildren(ctx context.Context, documentID, blockID string, start, end int64) error {
+	resp := new(rawDocxResp)
+	_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+		Scope:  "Drive",
+		API:    "BatchDeleteDocxBlock",
+		Method: "DELETE",
+		URL:    c.openBaseURL + "/open-apis/docx/v1/documents/:document_id/blocks/:block_id/children/batch_delete",
+		Body: &rawDeleteDocxBlockReq{
+			DocumentID: documentID,
+			BlockID:    blockID,
+			StartIndex: start,
+			EndIndex:   end,
+		},
+		NeedTenantAccessToken: true,
+	}, resp)
+	if err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("failed to delete blocks of %s: %s (%d)", blockID, resp.Msg, resp.Code)
+	}
+	return nil
+}
+
+// createDocxBlocks appends blocks to the children of a block, at most 50 per
+// request as the API allows, then fills in their children, table cells and
+// images, which need the IDs of the created blocks.
+func (c *Client) createDocxBlocks(ctx context.Context, documentID, parentID string, blocks []*UploadBlock) error {
+	for start := 0; start < len(blocks); start += 50 {
+		batch := blocks[start:min(start+50, len(blocks))]
+		children := make([]json.RawMessage, 0, len(batch))
+		for _, b := range batch {
+			data, err := docxBlockJSON(b.Block)
+			if err != nil {
+				return err
+			}
+			children = append(children, data)
+		}
+		resp := new(rawCreateDocxBlockResp)
+		_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:  "Drive",
+			API:    "CreateDocxBlock",
+			Method: "POST",
+			URL:    c.openBaseURL + "/open-apis/docx/v1/documents/:document_id/blocks/:block_id/children",
+			Body: &rawCreateDocxBlockReq{
+				DocumentID: documentID,
+				BlockID:    parentID,
+				Children:   children,
+			},
+			NeedTenantAccessToken: true,
+		}, resp)
+		if err != nil {
+			return err
+		}
+		if resp.Code != 0 || resp.Data == nil {
+			return fmt.Errorf("failed to create blocks in %s: %s (%d)", parentID, resp.Msg, resp.Code)
+		}
+		if len(resp.Data.Children) != len(batch) {
+			return fmt.Errorf("created %d blocks in %s, expected %d", len(resp.Data.Children), parentID, len(batch))
+		}
+		for i, created := range resp.Data.Children {
+			if err := c.fillDocxBlock(ctx, documentID, created, batch[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Client) fillDocxBlock(ctx context.Context, documentID string, created *lark.DocxBlock, b *UploadBlock) error {
+	if b.ImageData != nil {
+		if err := c.uploadDocxImage(ctx, documentID, created.BlockID, b); err != nil {
+			return err
+		}
+	}
+	if len(b.Cells) > 0 && created.Table != nil {
+		for i, cellID := range created.Table.Cells {
+			if i >= len(b.Cells) || len(b.Cells[i]) == 0 {
+				continue
+			}
+			if err := c.createDocxBlocks(ctx, documentID, cellID, b.Cells[i]); err != nil {
+				return err
+			}
+			// New cells come with an empty text block, now before the content
+			if err := c.deleteDocxChildren(ctx, documentID, cellID, 0, 1); err != nil {
+				return err
+			}
+		}
+	}
+	return c.createDocxBlocks(ctx, documentID, created.BlockID, b.Children)
+}
+
+// uploadDocxImage uploads the picture of an image block and sets it on the
+// block, which has to exist before the upload.
+func (c *Client) uploadDocxImage(ctx context.Context, documentID, blockID string, b *UploadBlock) error {
+	extra := fmt.Sprintf(`{"drive_route_token":"%s"}`, documentID)
+	resp, _, err := c.larkClient.Drive.UploadDriveMedia(ctx, &lark.UploadDriveMediaReq{
+		FileName:   b.ImageName,
+		ParentType: "docx_image",
+		ParentNode: blockID,
+		Size:       int64(len(b.ImageData)),
+		Extra:      &extra,
+		File:       bytes.NewReader(b.ImageData),
+	})
+	if err != nil {
+		return err
+	}
+	_, _, err = c.larkClient.Drive.UpdateDocxBlock(ctx, &lark.UpdateDocxBlockReq{
+		DocumentID:   documentID,
+		BlockID:      blockID,
+		ReplaceImage: &lark.UpdateDocxBlockReqReplaceImage{Token: resp.FileToken},
+	})
+	return err
+}
+
 func (c *Client) GetDriveFolderFileList(ctx context.Context, pageToken *string, folderToken *string) ([]*lark.GetDriveFileListRespFile, error) {
 	resp, _, err := c.larkClient.Drive.GetDriveFileList(ctx, &lark.GetDriveFileListReq{
 		PageSize:    nil,
@@ -160,7 +611,7 @@ func (c *Client) GetWikiNodeList(ctx context.Context, spaceID string, parentNode
 
 	for resp.HasMore && previousPageToken != resp.PageToken {
 		previousPageToken = resp.PageToken
-		resp, _, err := c.larkClient.Drive.GetWikiNodeList(ctx, &lark.GetWikiNodeListReq{
+		resp, _, err = c.larkClient.Drive.GetWikiNodeList(ctx, &lark.GetWikiNodeListReq{
 			SpaceID:         spaceID,
 			PageSize:        nil,
 			PageToken:       &resp.PageToken,
@@ -352,3 +803,160 @@ func (c *Client) getBitableRecordList(ctx context.Context, req *lark.GetBitableR
 	}
 	return resp, nil
 }
+
+// DownloadDriveFile downloads a file uploaded to the drive as-is into outDir,
+// named after its original file name or name when the drive has none, and
+// returns the path of the written file.
+func (c *Client) DownloadDriveFile(ctx context.Context, fileToken, outDir, name string) (string, error) {
+	resp, _, err := c.larkClient.Drive.DownloadDriveFile(ctx, &lark.DownloadDriveFileReq{
+		FileToken: fileToken,
+	})
+	if err != nil {
+		return "", err
+	}
+	if resp.Filename != "" {
+		name = resp.Filename
+	}
+	filename := filepath.Join(outDir, utils.SanitizeFileName(name))
+	return filename, writeFile(filename, resp.File)
+}
+
+// ExportPollInterval is the delay between checks of a running export task.
+var ExportPollInterval = time.Second
+
+// ExportDriveFile exports an online document, e.g. a sheet or a bitable as
+// xlsx, through an export task and writes the exported file to filename.
+func (c *Client) ExportDriveFile(ctx context.Context, token, objType, fileExtension, filename string) error {
+	task, _, err := c.larkClient.Drive.CreateDriveExportTask(ctx, &lark.CreateDriveExportTaskReq{
+		FileExtension: fileExtension,
+		Token:         token,
+		Type:          objType,
+	})
+	if err != nil {
+		return err
+	}
+	// The task usually finishes within seconds; give up after a minute
+	for i := 0; i < 60; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(ExportPollInterval):
+		}
+		resp, _, err := c.larkClient.Drive.GetDriveExportTask(ctx, &lark.GetDriveExportTaskReq{
+			Ticket: task.Ticket,
+			Token:  token,
+		})
+		if err != nil {
+			return err
+		}
+		result := resp.Result
+		switch {
+		case result == nil || result.JobStatus == 1 || result.JobStatus == 2:
+			continue
+		case result.JobStatus != 0:
+			return fmt.Errorf("failed to export %s %s: %s (%d)", objType, token, result.JobErrorMsg, result.JobStatus)
+		}
+		file, _, err := c.larkClient.Drive.DownloadDriveExportTask(ctx, &lark.DownloadDriveExportTaskReq{
+			FileToken: result.FileToken,
+		})
+		if err != nil {
+			return err
+		}
+		return writeFile(filename, file.File)
+	}
+	return fmt.Errorf("timed out exporting %s %s", objType, token)
+}
+
+// writeFile copies r into filename, removing the partly written file on failure.
+func writeFile(filename string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, r)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(filename)
+	}
+	return err
+}
+
+// SheetValues is the text of the cells of a worksheet.
+type SheetValues struct {
+	Title string
+	Rows  [][]string
+}
+
+type rawSheetValueReq struct {
+	SpreadsheetToken     string `path:"spreadsheet_token" json:"-"`
+	Range                string `path:"range" json:"-"`
+	ValueRenderOption    string `query:"valueRenderOption" json:"-"`
+	DateTimeRenderOption string `query:"dateTimeRenderOption" json:"-"`
+}
+
+// The SDK decodes cells into lark.SheetContent, which rejects decimal and
+// negative numbers, so the values are decoded here instead.
+type rawSheetValueResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data *struct {
+		ValueRange *struct {
+			Values [][]interface{} `json:"values"`
+		} `json:"valueRange"`
+	} `json:"data,omitempty"`
+}
+
+// GetSheetValues returns the formatted cell text of every worksheet of a
+// spreadsheet, in the order of the tabs.
+func (c *Client) GetSheetValues(ctx context.Context, spreadsheetToken string) ([]*SheetValues, error) {
+	list, _, err := c.larkClient.Drive.GetSheetList(ctx, &lark.GetSheetListReq{
+		SpreadSheetToken: spreadsheetToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sheets := make([]*SheetValues, 0, len(list.Sheets))
+	for _, s := range list.Sheets {
+		if s.ResourceType != "" && s.ResourceType != "sheet" {
+			// Embedded bitables and other non-grid tabs have no cell values
+			continue
+		}
+		resp := new(rawSheetValueResp)
+		_, err := c.larkClient.RawRequest(ctx, &lark.RawRequestReq{
+			Scope:  "Drive",
+			API:    "GetSheetValue",
+			Method: "GET",
+			URL:    c.openBaseURL + "/open-apis/sheets/v2/spreadsheets/:spreadsheet_token/values/:range",
+			Body: &rawSheetValueReq{
+				SpreadsheetToken:     spreadsheetToken,
+				Range:                s.SheetID,
+				ValueRenderOption:    "ToString",
+				DateTimeRenderOption: "FormattedString",
+			},
+			NeedTenantAccessToken: true,
+		}, resp)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Code != 0 {
+			return nil, fmt.Errorf("failed to get values of sheet %s: %s (%d)", s.Title, resp.Msg, resp.Code)
+		}
+		values := &SheetValues{Title: s.Title}
+		if resp.Data != nil && resp.Data.ValueRange != nil {
+			for _, row := range resp.Data.ValueRange.Values {
+				cells := make([]string, len(row))
+				for i, v := range row {
+					cells[i] = SheetCellText(v)
+				}
+				values.Rows = append(values.Rows, cells)
+			}
+		}
+		sheets = append(sheets, trimSheetValues(values))
+	}
+	return sheets, nil
+}
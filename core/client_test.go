@@ -2,7 +2,10 @@ package core_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -120,3 +123,30 @@ func TestGetWikiNodeList(t *testing.T) {
 		t.Errorf("Error: no nodes found")
 	}
 }
+
+func TestGetWikiNodeListPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+			return
+		}
+		data := map[string]interface{}{
+			"items":    []map[string]string{{"node_token": "n1"}},
+			"has_more": true, "page_token": "p1",
+		}
+		if r.URL.Query().Get("page_token") == "p1" {
+			data = map[string]interface{}{"items": []map[string]string{{"node_token": "n2"}}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+	}))
+	defer server.Close()
+
+	c := core.NewClientWithBaseURL("app", "secret", server.URL)
+	nodes, err := c.GetWikiNodeList(context.Background(), "space", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[1].NodeToken != "n2" {
+		t.Errorf("Error: expected nodes of both pages, got %d", len(nodes))
+	}
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chyroc/lark"
+)
+
+// Comment is a comment thread of a document. The first reply is the comment
+// itself; inline comments also carry the text they were made on.
+type Comment struct {
+	ID      string          `json:"comment_id"`
+	Whole   bool            `json:"is_whole"`
+	Solved  bool            `json:"is_solved"`
+	Quote   string          `json:"quote,omitempty"`
+	Replies []*CommentReply `json:"replies"`
+}
+
+type CommentReply struct {
+	AuthorID    string    `json:"author_id"`
+	Author      string    `json:"author,omitempty"`
+	CreatedTime time.Time `json:"created_time"`
+	Text        string    `json:"text"`
+	elements    []rawCommentElement
+}
+
+type rawCommentElement struct {
+	Type    string `json:"type,omitempty"`
+	TextRun *struct {
+		Text string `json:"text,omitempty"`
+	} `json:"text_run,omitempty"`
+	DocsLink *struct {
+		URL string `json:"url,omitempty"`
+	} `json:"docs_link,omitempty"`
+	Person *struct {
+		UserID string `json:"user_id,omitempty"`
+	} `json:"person,omitempty"`
+}
+
+// rawCommentListResp is the comment list response including is_whole and
+// quote, which lark.GetDriveCommentListResp leaves out.
+type rawCommentListResp struct {
+	Code int64  `json:"code,omitempty"`
+	Msg  string `json:"msg,omitempty"`
+	Data *struct {
+		Items []*struct {
+			CommentID string `json:"comment_id,omitempty"`
+			IsSolved  bool   `json:"is_solved,omitempty"`
+			IsWhole   bool   `json:"is_whole,omitempty"`
+			Quote     string `json:"quote,omitempty"`
+			ReplyList *struct {
+				Replies []*struct {
+					UserID     string `json:"user_id,omitempty"`
+					CreateTime int64  `json:"create_time,omitempty"`
+					Content    *struct {
+						Elements []rawCommentElement `json:"elements,omitempty"`
+					} `json:"content,omitempty"`
+				} `json:"replies,omitempty"`
+			} `json:"reply_list,omitempty"`
+		} `json:"items,omitempty"`
+		PageToken string `json:"page_token,omitempty"`
+		HasMore   bool   `json:"has_more,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// CommentUserIDs lists the authors and mentioned users of comments.
+func CommentUserIDs(comments []*Comment) []string {
+	ids := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, c := range comments {
+		for _, r := range c.Replies {
+			add(r.AuthorID)
+			for _, e := range r.elements {
+				if e.Person != nil {
+					add(e.Person.UserID)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// SetCommentUserNames fills in author names and renders mentions with the
+// display names of names, keyed by OpenID.
+func SetCommentUserNames(comments []*Comment, names map[string]string) {
+	for _, c := range comments {
+		for _, r := range c.Replies {
+			r.Author = names[r.AuthorID]
+			if r.elements != nil {
+				r.Text = renderCommentElements(r.elements, names)
+			}
+		}
+	}
+}
+
+func renderCommentElements(elements []rawCommentElement, names map[string]string) string {
+	buf := new(strings.Builder)
+	for _, e := range elements {
+		switch {
+		case e.TextRun != nil:
+			buf.WriteString(e.TextRun.Text)
+		case e.DocsLink != nil:
+			buf.WriteString(e.DocsLink.URL)
+		case e.Person != nil:
+			if name := names[e.Person.UserID]; name != "" {
+				buf.WriteString("@" + name)
+			} else {
+				buf.WriteString("@" + e.Person.UserID)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// author returns the display name of the reply author, falling back to the ID.
+func (r *CommentReply) author() string {
+	if r.Author != "" {
+		return r.Author
+	}
+	if r.AuthorID != "" {
+		return r.AuthorID
+	}
+	return "?"
+}
+
+// =============================================================
+// Render comments
+// =============================================================
+
+// docxBlockText returns the text payload of a text-like block.
+func docxBlockText(b *lark.DocxBlock) *lark.DocxBlockText {
+	for _, t := range []*lark.DocxBlockText{
+		b.Page, b.Text, b.Heading1, b.Heading2, b.Heading3, b.Heading4,
+		b.Heading5, b.Heading6, b.Heading7, b.Heading8, b.Heading9,
+		b.Bullet, b.Ordered, b.Code, b.Quote, b.Todo,
+	} {
+		if t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+// indexCommentAnchors maps each text element carrying inline comments to the
+// comments anchored on it.
+func (p *Parser) indexCommentAnchors(blocks []*lark.DocxBlock) {
+	for _, b := range blocks {
+		ext := p.blockExts[b.BlockID]
+		if ext == nil || ext.Comments == nil {
+			continue
+		}
+		text := docxBlockText(b)
+		if text == nil {
+			continue
+		}
+		for i, ids := range ext.Comments.Elements {
+			if i >= len(text.Elements) {
+				break
+			}
+			for _, id := range ids {
+				if _, ok := p.comments[id]; ok {
+					p.commentAnchors[text.Elements[i]] = append(p.commentAnchors[text.Elements[i]], id)
+				}
+			}
+		}
+	}
+}
+
+// commentFootnoteRefs renders the footnote references of the comments
+// anchored on e, numbering comments in the order they first appear.
+func (p *Parser) commentFootnoteRefs(e *lark.DocxTextElement) string {
+	buf := new(strings.Builder)
+	for _, id := range p.commentAnchors[e] {
+		n, ok := p.commentFootnotes[id]
+		if !ok {
+			p.commentOrder = append(p.commentOrder, id)
+			n = len(p.commentOrder)
+			p.commentFootnotes[id] = n
+		}
+		buf.WriteString(fmt.Sprintf("[^%d]", n))
+	}
+	return buf.String()
+}
+
+// renderComments renders the footnote definitions of the anchored comments
+// and a "Comments" appendix with the rest, or with all of them in appendix
+// mode.
+func (p *Parser) renderComments() string {
+	if len(p.commentList) == 0 {
+		return ""
+	}
+	buf := new(strings.Builder)
+	for _, id := range p.commentOrder {
+		c := p.comments[id]
+		buf.WriteString(fmt.Sprintf("\n[^%d]: ", p.commentFootnotes[id]))
+		for i, r := range c.Replies {
+			if i > 0 {
+				buf.WriteString("\n\n    ")
+			}
+			buf.WriteString(formatCommentReply(r))
+		}
+		if c.Solved {
+			buf.WriteString(" *(resolved)*")
+		}
+		buf.WriteString("\n")
+	}
+
+	appendix := new(strings.Builder)
+	for _, c := range p.commentList {
+		if _, ok := p.commentFootnotes[c.ID]; ok {
+			continue
+		}
+		appendix.WriteString("\n")
+		if c.Quote != "" {
+			appendix.WriteString("> " + strings.ReplaceAll(c.Quote, "\n", " ") + "\n\n")
+		}
+		for _, r := range c.Replies {
+			appendix.WriteString("- " + formatCommentReply(r) + "\n")
+		}
+		if c.Solved {
+			appendix.WriteString("\n*(resolved)*\n")
+		}
+	}
+	if appendix.Len() > 0 {
+		buf.WriteString("\n## Comments\n")
+		buf.WriteString(appendix.String())
+	}
+	return buf.String()
+}
+
+func formatCommentReply(r *CommentReply) string {
+	text := strings.Join(strings.Fields(r.Text), " ")
+	if r.CreatedTime.IsZero() {
+		return fmt.Sprintf("**%s**: %s", r.author(), text)
+	}
+	return fmt.Sprintf("**%s** (%s): %s", r.author(), r.CreatedTime.Format("2006-01-02 15:04"), text)
+}
@@ -2,9 +2,13 @@ package core
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 )
 
 type Config struct {
@@ -22,6 +26,83 @@ type OutputConfig struct {
 	TitleAsFilename bool   `json:"title_as_filename"`
 	UseHTMLTags     bool   `json:"use_html_tags"`
 	SkipImgDownload bool   `json:"skip_img_download"`
+	// TableStyle is "auto" (pipe tables unless cells are merged), "gfm" or "html"
+	TableStyle string `json:"table_style"`
+	// InlineBitable renders embedded bitable blocks as tables in the document,
+	// off by default as it fetches the records of every embedded bitable
+	InlineBitable bool `json:"inline_bitable"`
+	// BitableMaxRows caps the rows rendered per embedded bitable, 0 means no cap
+	BitableMaxRows int `json:"bitable_max_rows"`
+	// SyncedBlockMode is "inline" to expand synced block references in place,
+	// or "transclusion" to emit Obsidian ![[...]] embeds of the exported source
+	// document, falling back to inline when it isn't exported in the same run
+	// or the source block has more than one child
+	SyncedBlockMode string `json:"synced_block_mode"`
+	// GridStyle lays out grid columns "sequential" (one after another),
+	// or side by side as an HTML "table" or "flex" divs
+	GridStyle string `json:"grid_style"`
+	// ImageAttributes appends Pandoc/Hugo style {width=... height=...} to
+	// Markdown images
+	ImageAttributes bool `json:"image_attributes"`
+	// AssetsLayout is "per_doc" to store images next to each document, or
+	// "shared" to store them once by content hash under AssetsDir
+	AssetsLayout string `json:"assets_layout"`
+	// AssetsDir is the shared assets directory, relative to the output root
+	AssetsDir string `json:"assets_dir"`
+	// ImageConcurrency is the number of images downloaded in parallel per
+	// document; requests still share the client's rate limiter
+	ImageConcurrency int `json:"image_concurrency"`
+	// ImageFormat converts downloaded PNG/JPEG images to "webp" or "jpeg";
+	// empty keeps the original format
+	ImageFormat string `json:"image_format"`
+	// ImageConvertMinBytes only converts images at least this large
+	ImageConvertMinBytes int `json:"image_convert_min_bytes"`
+	// ImageMaxWidth scales wider images down to this width, 0 means no cap
+	ImageMaxWidth int `json:"image_max_width"`
+	// ImageJPEGQuality is the quality used when encoding JPEG, 1 to 100
+	ImageJPEGQuality int `json:"image_jpeg_quality"`
+	// StripEXIF removes EXIF and text metadata from downloaded images, keeping
+	// the orientation of JPEG photos
+	StripEXIF bool `json:"strip_exif"`
+	// ImageMode is "link" to download images next to the document,
+	// "data_uri" to embed them as base64, or "remote" to keep Feishu URLs
+	ImageMode string `json:"image_mode"`
+	// DataURIMaxBytes caps the size of embedded images; larger ones are
+	// downloaded as in "link" mode
+	DataURIMaxBytes int `json:"data_uri_max_bytes"`
+	// FrontMatter prepends "yaml" or "toml" front matter, empty for none
+	FrontMatter string `json:"front_matter"`
+	// FrontMatterFields picks and orders the fields of DocumentMeta to emit
+	FrontMatterFields []string `json:"front_matter_fields"`
+	// TOC inserts a table of contents after the title: "github" for a list
+	// of links, "marker" for a [TOC] marker, empty for none
+	TOC string `json:"toc"`
+	// TOCDepth is the deepest heading level listed in the TOC
+	TOCDepth int `json:"toc_depth"`
+	// SlugStyle generates heading anchors: "github", "hugo" or "pinyin"
+	SlugStyle string `json:"slug_style"`
+	// HeadingAnchors writes anchors into headings as "attr" ({#id}) or
+	// "html" (<a id>), empty to rely on the renderer's own IDs
+	HeadingAnchors string `json:"heading_anchors"`
+	// DeepHeadings handles headings past H6: "clamp" them to H6, render them
+	// as "bold" paragraphs, or "shift" all levels to start right below the title
+	DeepHeadings string `json:"deep_headings"`
+	// OmitTitle leaves out the "# title" line, e.g. when front matter has it
+	OmitTitle bool `json:"omit_title"`
+	// Comments exports document comments as "footnotes" anchored at the
+	// commented text or as an "appendix" section, empty for none
+	Comments string `json:"comments"`
+	// CommentsJSON writes the comments to a <name>.comments.json side-car file
+	CommentsJSON bool `json:"comments_json"`
+	// ParentPageLayout places the content of a wiki page with children as a
+	// "sibling" Title.md next to its Title/ folder, or inside the folder as
+	// "index" (index.md), "_index" (_index.md) or "readme" (README.md)
+	ParentPageLayout string `json:"parent_page_layout"`
+	// NonDocx maps the type of a non-docx wiki or folder item to its handler
+	// during bulk export: "xlsx" or "csv" for sheet and bitable, "download"
+	// for file, "stub" for a Markdown link to Feishu, or "skip". Types not
+	// listed are skipped
+	NonDocx map[string]string `json:"non_docx"`
 }
 
 func NewConfig(appId, appSecret string) *Config {
@@ -31,10 +112,38 @@ func NewConfig(appId, appSecret string) *Config {
 			AppSecret: appSecret,
 		},
 		Output: OutputConfig{
-			ImageDir:        "static",
-			TitleAsFilename: false,
-			UseHTMLTags:     false,
-			SkipImgDownload: false,
+			ImageDir:             "static",
+			TitleAsFilename:      false,
+			UseHTMLTags:          false,
+			SkipImgDownload:      false,
+			TableStyle:           "auto",
+			InlineBitable:        false,
+			BitableMaxRows:       50,
+			SyncedBlockMode:      "inline",
+			GridStyle:            "sequential",
+			ImageAttributes:      false,
+			AssetsLayout:         "per_doc",
+			AssetsDir:            "assets",
+			ImageConcurrency:     4,
+			ImageFormat:          "",
+			ImageConvertMinBytes: 200 << 10,
+			ImageMaxWidth:        0,
+			ImageJPEGQuality:     85,
+			StripEXIF:            false,
+			ImageMode:            "link",
+			DataURIMaxBytes:      1 << 20,
+			FrontMatter:          "",
+			FrontMatterFields:    append([]string(nil), DefaultFrontMatterFields...),
+			TOC:                  "",
+			TOCDepth:             3,
+			SlugStyle:            "github",
+			HeadingAnchors:       "",
+			DeepHeadings:         "clamp",
+			OmitTitle:            false,
+			Comments:             "",
+			CommentsJSON:         false,
+			ParentPageLayout:     "sibling",
+			NonDocx:              map[string]string{},
 		},
 	}
 }
@@ -61,6 +170,61 @@ func ReadConfigFromFile(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// outputOptions lists the values of each enum option of OutputConfig, the
+// empty string standing for the default where the option has one.
+var outputOptions = []struct {
+	name   string
+	value  func(c *OutputConfig) string
+	values []string
+}{
+	{"table_style", func(c *OutputConfig) string { return c.TableStyle }, []string{"", "auto", "gfm", "html"}},
+	{"synced_block_mode", func(c *OutputConfig) string { return c.SyncedBlockMode }, []string{"", "inline", "transclusion"}},
+	{"grid_style", func(c *OutputConfig) string { return c.GridStyle }, []string{"", "sequential", "table", "flex"}},
+	{"assets_layout", func(c *OutputConfig) string { return c.AssetsLayout }, []string{"", "per_doc", "shared"}},
+	{"image_format", func(c *OutputConfig) string { return c.ImageFormat }, []string{"", "webp", "jpeg", "jpg"}},
+	{"image_mode", func(c *OutputConfig) string { return c.ImageMode }, []string{"", "link", "data_uri", "remote"}},
+	{"front_matter", func(c *OutputConfig) string { return c.FrontMatter }, []string{"", "yaml", "toml"}},
+	{"toc", func(c *OutputConfig) string { return c.TOC }, []string{"", "github", "marker"}},
+	{"slug_style", func(c *OutputConfig) string { return c.SlugStyle }, []string{"", "github", "hugo", "pinyin"}},
+	{"heading_anchors", func(c *OutputConfig) string { return c.HeadingAnchors }, []string{"", "attr", "html"}},
+	{"deep_headings", func(c *OutputConfig) string { return c.DeepHeadings }, []string{"", "clamp", "bold", "shift"}},
+	{"comments", func(c *OutputConfig) string { return c.Comments }, []string{"", "footnotes", "appendix"}},
+	{"parent_page_layout", func(c *OutputConfig) string { return c.ParentPageLayout }, []string{"", "sibling", "index", "_index", "readme"}},
+}
+
+// nonDocxHandlers lists the handlers non_docx accepts for each item type,
+// on top of "stub" and "skip" which apply to any type.
+var nonDocxHandlers = map[string][]string{
+	"sheet":   {"xlsx", "csv"},
+	"bitable": {"xlsx", "csv"},
+	"file":    {"download"},
+}
+
+// Validate checks the values of the enum options, so that a typo in the
+// configuration fails before anything is downloaded instead of silently
+// falling back to the default.
+func (c *OutputConfig) Validate() error {
+	for _, o := range outputOptions {
+		value := o.value(c)
+		if !slices.Contains(o.values, value) {
+			return fmt.Errorf("unknown %s %q, expected %s", o.name, value, strings.Join(o.values[1:], ", "))
+		}
+	}
+	types := make([]string, 0, len(c.NonDocx))
+	for objType := range c.NonDocx {
+		types = append(types, objType)
+	}
+	sort.Strings(types)
+	for _, objType := range types {
+		handler := c.NonDocx[objType]
+		handlers := append([]string{"stub", "skip"}, nonDocxHandlers[objType]...)
+		if !slices.Contains(handlers, handler) {
+			return fmt.Errorf("unknown non_docx handler %q for %s, expected %s", handler, objType, strings.Join(handlers, ", "))
+		}
+	}
+	return nil
+}
+
 func (conf *Config) WriteConfig2File(configPath string) error {
 	err := os.MkdirAll(filepath.Dir(configPath), 0o755)
 	if err != nil {
@@ -0,0 +1,46 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputConfigValidate(t *testing.T) {
+	assert.NoError(t, core.NewConfig("", "").Output.Validate())
+
+	config := core.NewConfig("", "").Output
+	config.ImageMode, config.TOC, config.NonDocx = "", "", map[string]string{"sheet": "csv", "mindnote": "stub"}
+	assert.NoError(t, config.Validate())
+
+	tests := []struct {
+		name   string
+		modify func(c *core.OutputConfig)
+		err    string
+	}{
+		{"image_mode", func(c *core.OutputConfig) { c.ImageMode = "inline" }, `unknown image_mode "inline", expected link, data_uri, remote`},
+		{"table_style", func(c *core.OutputConfig) { c.TableStyle = "markdown" }, `unknown table_style "markdown"`},
+		{"grid_style", func(c *core.OutputConfig) { c.GridStyle = "grid" }, `unknown grid_style "grid"`},
+		{"synced_block_mode", func(c *core.OutputConfig) { c.SyncedBlockMode = "embed" }, `unknown synced_block_mode "embed"`},
+		{"front_matter", func(c *core.OutputConfig) { c.FrontMatter = "json" }, `unknown front_matter "json"`},
+		{"toc", func(c *core.OutputConfig) { c.TOC = "gitlab" }, `unknown toc "gitlab"`},
+		{"slug_style", func(c *core.OutputConfig) { c.SlugStyle = "jekyll" }, `unknown slug_style "jekyll"`},
+		{"deep_headings", func(c *core.OutputConfig) { c.DeepHeadings = "drop" }, `unknown deep_headings "drop"`},
+		{"parent_page_layout", func(c *core.OutputConfig) { c.ParentPageLayout = "README" }, `unknown parent_page_layout "README"`},
+		{"image_format", func(c *core.OutputConfig) { c.ImageFormat = "avif" }, `unknown image_format "avif"`},
+		{"comments", func(c *core.OutputConfig) { c.Comments = "inline" }, `unknown comments "inline"`},
+		{"non_docx", func(c *core.OutputConfig) { c.NonDocx = map[string]string{"file": "xlsx"} },
+			`unknown non_docx handler "xlsx" for file, expected stub, skip, download`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := core.NewConfig("", "").Output
+			tt.modify(&config)
+			err := config.Validate()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// DiffStat counts the lines added and removed between two texts.
+type DiffStat struct {
+	Added   int
+	Removed int
+}
+
+// Changed reports whether the texts differ.
+func (s DiffStat) Changed() bool {
+	return s.Added > 0 || s.Removed > 0
+}
+
+// diffLines returns the hunks of a line diff with context lines around each
+// change. Auto-junk is off so that frequent lines such as blank ones still
+// anchor the diff of long documents.
+func diffLines(a, b []string, context int) [][]difflib.OpCode {
+	return difflib.NewMatcherWithJunk(a, b, false, nil).GetGroupedOpCodes(context)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func hunkHeader(codes []difflib.OpCode) string {
+	first, last := codes[0], codes[len(codes)-1]
+	return fmt.Sprintf("@@ -%s +%s @@\n",
+		hunkRange(first.I1, last.I2), hunkRange(first.J1, last.J2))
+}
+
+func hunkRange(start, stop int) string {
+	length := stop - start
+	if length == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	if length == 0 {
+		// An empty range is given as the line before it
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// writeDiffLine writes a diff line, marking a missing final newline the way
+// diff(1) does.
+func writeDiffLine(buf *strings.Builder, prefix, line string) {
+	buf.WriteString(prefix + line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// UnifiedDiff renders the changes from before to after as a unified diff with
+// three lines of context, and counts the changed lines.
+func UnifiedDiff(before, after, beforeName, afterName string) (string, DiffStat) {
+	a, b := splitLines(before), splitLines(after)
+	stat := DiffStat{}
+	buf := new(strings.Builder)
+	for i, codes := range diffLines(a, b, 3) {
+		if i == 0 {
+			buf.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", beforeName, afterName))
+		}
+		buf.WriteString(hunkHeader(codes))
+		for _, c := range codes {
+			if c.Tag == 'e' {
+				for _, line := range a[c.I1:c.I2] {
+					writeDiffLine(buf, " ", line)
+				}
+				continue
+			}
+			for _, line := range a[c.I1:c.I2] {
+				writeDiffLine(buf, "-", line)
+				stat.Removed++
+			}
+			for _, line := range b[c.J1:c.J2] {
+				writeDiffLine(buf, "+", line)
+				stat.Added++
+			}
+		}
+	}
+	return buf.String(), stat
+}
+
+// WordDiff renders the changed lines from before to after with the words in
+// between marked like git's --word-diff=plain: [-removed-]{+added+}. CJK
+// characters count as words of their own.
+func WordDiff(before, after string) (string, DiffStat) {
+	a, b := splitLines(before), splitLines(after)
+	stat := DiffStat{}
+	buf := new(strings.Builder)
+	for _, codes := range diffLines(a, b, 0) {
+		buf.WriteString(hunkHeader(codes))
+		for _, c := range codes {
+			stat.Removed += c.I2 - c.I1
+			stat.Added += c.J2 - c.J1
+			buf.WriteString(diffWords(
+				strings.Join(a[c.I1:c.I2], ""), strings.Join(b[c.J1:c.J2], "")))
+		}
+		if !strings.HasSuffix(buf.String(), "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return buf.String(), stat
+}
+
+func diffWords(before, after string) string {
+	a, b := splitWords(before), splitWords(after)
+	buf := new(strings.Builder)
+	for _, c := range difflib.NewMatcherWithJunk(a, b, false, nil).GetOpCodes() {
+		removed := strings.Join(a[c.I1:c.I2], "")
+		added := strings.Join(b[c.J1:c.J2], "")
+		switch c.Tag {
+		case 'e':
+			buf.WriteString(removed)
+		default:
+			if removed != "" {
+				buf.WriteString("[-" + removed + "-]")
+			}
+			if added != "" {
+				buf.WriteString("{+" + added + "+}")
+			}
+		}
+	}
+	return buf.String()
+}
+
+// splitWords splits text into runs of letters and digits, runs of spaces,
+// and single other characters, so that joining them gives the text back.
+func splitWords(s string) []string {
+	words := make([]string, 0)
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		case isWordRune(r):
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+		}
+		words = append(words, string(runes[i:j]))
+		i = j
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	if unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return false
+	}
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_'
+}
+
+// ConflictMarkers merges two versions of a text line by line, keeping the
+// lines they share and wrapping every hunk where they differ in git-style
+// conflict markers labelled with oursName and theirsName.
+func ConflictMarkers(ours, theirs, oursName, theirsName string) string {
+	a, b := splitLines(ours), splitLines(theirs)
+	buf := new(strings.Builder)
+	writeLines := func(lines []string) {
+		for _, line := range lines {
+			buf.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				buf.WriteString("\n")
+			}
+		}
+	}
+	for _, c := range difflib.NewMatcherWithJunk(a, b, false, nil).GetOpCodes() {
+		if c.Tag == 'e' {
+			writeLines(a[c.I1:c.I2])
+			continue
+		}
+		buf.WriteString("<<<<<<< " + oursName + "\n")
+		writeLines(a[c.I1:c.I2])
+		buf.WriteString("=======\n")
+		writeLines(b[c.J1:c.J2])
+		buf.WriteString(">>>>>>> " + theirsName + "\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "# Spec\n\nScope: all\n\nOwner: Ann\n"
+	new := "# Spec\n\nScope: some\n\nOwner: Ann\n\nRisks\n"
+
+	diff, stat := core.UnifiedDiff(old, new, "local/spec.md", "remote")
+	assert.Equal(t, "--- local/spec.md\n+++ remote\n"+
+		"@@ -1,5 +1,7 @@\n # Spec\n \n-Scope: all\n+Scope: some\n \n Owner: Ann\n+\n+Risks\n", diff)
+	assert.Equal(t, core.DiffStat{Added: 3, Removed: 1}, stat)
+
+	diff, stat = core.UnifiedDiff(old, old, "a", "b")
+	assert.Empty(t, diff)
+	assert.False(t, stat.Changed())
+
+	diff, _ = core.UnifiedDiff("a", "b", "a", "b")
+	assert.Equal(t, "--- a\n+++ b\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n", diff)
+}
+
+func TestWordDiff(t *testing.T) {
+	old := "# Spec\n\nShip the beta on Friday.\n\n周五发布测试版\n"
+	new := "# Spec\n\nShip the release on Monday.\n\n周一发布测试版\n"
+
+	diff, stat := core.WordDiff(old, new)
+	assert.Equal(t, "@@ -3 +3 @@\nShip the [-beta-]{+release+} on [-Friday-]{+Monday+}.\n"+
+		"@@ -5 +5 @@\n周[-五-]{+一+}发布测试版\n", diff)
+	assert.Equal(t, core.DiffStat{Added: 2, Removed: 2}, stat)
+}
+
+func TestConflictMarkers(t *testing.T) {
+	ours := "# Spec\n\nScope: all\n\nOwner: Ann\n"
+	theirs := "# Spec\n\nScope: some\n\nOwner: Ann\n\nRisks"
+
+	assert.Equal(t, "# Spec\n\n"+
+		"<<<<<<< local\nScope: all\n=======\nScope: some\n>>>>>>> remote\n"+
+		"\nOwner: Ann\n"+
+		"<<<<<<< local\n=======\n\nRisks\n>>>>>>> remote\n",
+		core.ConflictMarkers(ours, theirs, "local", "remote"))
+	assert.Equal(t, ours, core.ConflictMarkers(ours, ours, "local", "remote"))
+}
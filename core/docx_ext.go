@@ -0,0 +1,202 @@
+package core
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/chyroc/lark"
+)
+
+// Block types that are newer than the lark SDK this project pins.
+const (
+	DocxBlockTypeTask            lark.DocxBlockType = 35
+	DocxBlockTypeOKR             lark.DocxBlockType = 36
+	DocxBlockTypeOKRObjective    lark.DocxBlockType = 37
+	DocxBlockTypeOKRKeyResult    lark.DocxBlockType = 38
+	DocxBlockTypeOKRProgress     lark.DocxBlockType = 39
+	DocxBlockTypeAddOns          lark.DocxBlockType = 40
+	DocxBlockTypeJiraIssue       lark.DocxBlockType = 41
+	DocxBlockTypeWikiCatalog     lark.DocxBlockType = 42
+	DocxBlockTypeBoard           lark.DocxBlockType = 43
+	DocxBlockTypeAgenda          lark.DocxBlockType = 44
+	DocxBlockTypeAgendaItem      lark.DocxBlockType = 45
+	DocxBlockTypeAgendaItemTitle lark.DocxBlockType = 46
+	DocxBlockTypeAgendaContent   lark.DocxBlockType = 47
+	DocxBlockTypeLinkPreview     lark.DocxBlockType = 48
+	DocxBlockTypeSourceSynced    lark.DocxBlockType = 49
+	DocxBlockTypeReferenceSynced lark.DocxBlockType = 50
+	DocxBlockTypeSubPageList     lark.DocxBlockType = 51
+)
+
+// DocxBlockExt carries the block payloads the lark SDK does not model yet.
+// It is decoded from the same raw JSON as lark.DocxBlock and keyed by block ID.
+type DocxBlockExt struct {
+	Board       *DocxBlockBoard       `json:"board,omitempty"`
+	Image       *DocxBlockImageExt    `json:"image,omitempty"`
+	Ordered     *DocxBlockOrderedExt  `json:"ordered,omitempty"`
+	Task        *DocxBlockTask        `json:"task,omitempty"`
+	OKR         *DocxBlockOKR         `json:"okr,omitempty"`
+	AddOns      *DocxBlockAddOns      `json:"add_ons,omitempty"`
+	JiraIssue   *DocxBlockJiraIssue   `json:"jira_issue,omitempty"`
+	LinkPreview *DocxBlockLinkPreview `json:"link_preview,omitempty"`
+	// ReferenceSynced points at the source block of a synced block reference
+	ReferenceSynced *DocxBlockReferenceSynced `json:"reference_synced,omitempty"`
+	// Comments holds the inline comment anchors of the block's text
+	Comments *DocxBlockComments `json:"-"`
+}
+
+type DocxBlockBoard struct {
+	Token  string `json:"token,omitempty"`
+	Width  int64  `json:"width,omitempty"`
+	Height int64  `json:"height,omitempty"`
+}
+
+// DocxBlockImageExt holds the image fields missing from lark.DocxBlockImage.
+type DocxBlockImageExt struct {
+	// Align is 1 for left, 2 for center and 3 for right
+	Align   int64             `json:"align,omitempty"`
+	Caption *DocxImageCaption `json:"caption,omitempty"`
+}
+
+type DocxImageCaption struct {
+	Content string `json:"content,omitempty"`
+}
+
+// DocxBlockOrderedExt holds the list numbering missing from lark.DocxTextStyle.
+type DocxBlockOrderedExt struct {
+	Style *DocxOrderedStyle `json:"style,omitempty"`
+}
+
+type DocxOrderedStyle struct {
+	// Sequence is the item number, or "auto" to continue the previous list
+	Sequence string `json:"sequence,omitempty"`
+}
+
+type DocxBlockTask struct {
+	TaskID string `json:"task_id,omitempty"`
+}
+
+type DocxBlockOKR struct {
+	OKRID        string `json:"okr_id,omitempty"`
+	PeriodNameZh string `json:"period_name_zh,omitempty"`
+	PeriodNameEn string `json:"period_name_en,omitempty"`
+}
+
+type DocxBlockAddOns struct {
+	ComponentID     string `json:"component_id,omitempty"`
+	ComponentTypeID string `json:"component_type_id,omitempty"`
+}
+
+type DocxBlockJiraIssue struct {
+	ID  string `json:"id,omitempty"`
+	Key string `json:"key,omitempty"`
+}
+
+type DocxBlockLinkPreview struct {
+	URL     string `json:"url,omitempty"`
+	URLType string `json:"url_type,omitempty"`
+}
+
+type DocxBlockReferenceSynced struct {
+	SourceBlockID    string `json:"source_block_id,omitempty"`
+	SourceDocumentID string `json:"source_document_id,omitempty"`
+}
+
+// DocxBlockComments lists the comment IDs on each element of a block's text,
+// which lark.DocxTextElementStyle does not model.
+type DocxBlockComments struct {
+	Elements [][]string `json:"elements"`
+}
+
+// rawBlockText is the part of a text payload carrying comment anchors.
+type rawBlockText struct {
+	Elements []struct {
+		TextRun *struct {
+			Style *struct {
+				CommentIDs []string `json:"comment_ids,omitempty"`
+			} `json:"text_element_style,omitempty"`
+		} `json:"text_run,omitempty"`
+	} `json:"elements"`
+}
+
+// decodeBlockComments finds the comment anchors in whichever field of a raw
+// block holds its text.
+func decodeBlockComments(item json.RawMessage) *DocxBlockComments {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return nil
+	}
+	for _, field := range fields {
+		text := new(rawBlockText)
+		if err := json.Unmarshal(field, text); err != nil || len(text.Elements) == 0 {
+			continue
+		}
+		comments := &DocxBlockComments{Elements: make([][]string, len(text.Elements))}
+		found := false
+		for i, e := range text.Elements {
+			if e.TextRun != nil && e.TextRun.Style != nil && len(e.TextRun.Style.CommentIDs) > 0 {
+				comments.Elements[i] = e.TextRun.Style.CommentIDs
+				found = true
+			}
+		}
+		if found {
+			return comments
+		}
+	}
+	return nil
+}
+
+// SyncedSource is another document fetched to resolve synced block references.
+type SyncedSource struct {
+	Title     string
+	Blocks    []*lark.DocxBlock
+	BlockExts map[string]*DocxBlockExt
+	// Link is the exported source document relative to the parsed one,
+	// without the .md extension. Transclusions embed it, and references are
+	// inlined instead when it is empty.
+	Link string
+}
+
+// SyncedSourceDocumentIDs lists the documents other than docID that hold the
+// source of a synced block referenced from exts.
+func SyncedSourceDocumentIDs(exts map[string]*DocxBlockExt, docID string) []string {
+	ids := make([]string, 0)
+	seen := map[string]bool{docID: true}
+	for _, ext := range exts {
+		if ext.ReferenceSynced == nil {
+			continue
+		}
+		id := ext.ReferenceSynced.SourceDocumentID
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// decodeDocxBlocks decodes raw block items into SDK blocks plus the extra
+// payloads, skipping extras for blocks that carry none.
+func decodeDocxBlocks(items []json.RawMessage) ([]*lark.DocxBlock, map[string]*DocxBlockExt, error) {
+	blocks := make([]*lark.DocxBlock, 0, len(items))
+	exts := make(map[string]*DocxBlockExt)
+	for _, item := range items {
+		block := new(lark.DocxBlock)
+		if err := json.Unmarshal(item, block); err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, block)
+
+		ext := new(DocxBlockExt)
+		if err := json.Unmarshal(item, ext); err != nil {
+			return nil, nil, err
+		}
+		ext.Comments = decodeBlockComments(item)
+		if *ext != (DocxBlockExt{}) {
+			exts[block.BlockID] = ext
+		}
+	}
+	return blocks, exts, nil
+}
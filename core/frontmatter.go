@@ -0,0 +1,234 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentMeta is the document and drive metadata front matter is built from.
+type DocumentMeta struct {
+	Title       string
+	DocumentID  string
+	RevisionID  int64
+	SourceURL   string
+	WikiPath    []string // titles of the ancestor wiki nodes, root first
+	Owner       string
+	CreatedTime time.Time
+	UpdatedTime time.Time
+	Group       string
+}
+
+// DefaultFrontMatterFields lists every field DocumentMeta can provide.
+var DefaultFrontMatterFields = []string{
+	"title",
+	"document_id",
+	"revision",
+	"source_url",
+	"wiki_path",
+	"owner",
+	"created_time",
+	"updated_time",
+	"group",
+}
+
+// FrontMatterNeedsDriveMeta reports whether fields use metadata that only the
+// drive meta API provides.
+func FrontMatterNeedsDriveMeta(fields []string) bool {
+	for _, f := range fields {
+		if f == "owner" || f == "created_time" || f == "updated_time" {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the named field, or nil when it is unknown or empty.
+func (m *DocumentMeta) value(field string) interface{} {
+	switch field {
+	case "title":
+		if m.Title != "" {
+			return m.Title
+		}
+	case "document_id":
+		if m.DocumentID != "" {
+			return m.DocumentID
+		}
+	case "revision":
+		if m.RevisionID != 0 {
+			return m.RevisionID
+		}
+	case "source_url":
+		if m.SourceURL != "" {
+			return m.SourceURL
+		}
+	case "wiki_path":
+		if len(m.WikiPath) > 0 {
+			return m.WikiPath
+		}
+	case "owner":
+		if m.Owner != "" {
+			return m.Owner
+		}
+	case "created_time":
+		if !m.CreatedTime.IsZero() {
+			return m.CreatedTime
+		}
+	case "updated_time":
+		if !m.UpdatedTime.IsZero() {
+			return m.UpdatedTime
+		}
+	case "group":
+		if m.Group != "" {
+			return m.Group
+		}
+	}
+	return nil
+}
+
+type frontMatterEntry struct {
+	key   string
+	value interface{}
+}
+
+// RenderFrontMatter renders the given fields of meta, followed by the static
+// keys in extra, as a "yaml" (---) or "toml" (+++) block. Empty fields are
+// left out and extra keys override fields of the same name.
+func RenderFrontMatter(format string, fields []string, meta *DocumentMeta, extra map[string]interface{}) (string, error) {
+	entries := make([]frontMatterEntry, 0, len(fields)+len(extra))
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+		value, ok := extra[field]
+		if !ok {
+			value = meta.value(field)
+		}
+		if value != nil {
+			entries = append(entries, frontMatterEntry{field, value})
+		}
+	}
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		entries = append(entries, frontMatterEntry{key, extra[key]})
+	}
+
+	switch format {
+	case "yaml":
+		return renderYAMLFrontMatter(entries)
+	case "toml":
+		return renderTOMLFrontMatter(entries)
+	}
+	return "", fmt.Errorf("unknown front matter format %q", format)
+}
+
+func renderYAMLFrontMatter(entries []frontMatterEntry) (string, error) {
+	// Build the node tree by hand to keep the configured key order
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, e := range entries {
+		value := new(yaml.Node)
+		if err := value.Encode(e.value); err != nil {
+			return "", err
+		}
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: e.key}, value)
+	}
+	out := new(strings.Builder)
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return "---\n" + out.String() + "---\n\n", nil
+}
+
+func renderTOMLFrontMatter(entries []frontMatterEntry) (string, error) {
+	buf := new(strings.Builder)
+	buf.WriteString("+++\n")
+	for _, e := range entries {
+		value, err := tomlValue(e.value)
+		if err != nil {
+			return "", fmt.Errorf("front matter key %q: %v", e.key, err)
+		}
+		buf.WriteString(tomlKey(e.key) + " = " + value + "\n")
+	}
+	buf.WriteString("+++\n\n")
+	return buf.String(), nil
+}
+
+var tomlBareKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKeyRegexp.MatchString(key) {
+		return key
+	}
+	quoted, _ := tomlValue(key)
+	return quoted
+}
+
+// tomlValue encodes the scalars, lists and maps that YAML templates and
+// DocumentMeta produce; maps become inline tables.
+func tomlValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		// JSON string escapes are all valid in TOML basic strings
+		out, err := json.Marshal(v)
+		return string(out), err
+	case time.Time:
+		return v.Format(time.RFC3339), nil
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case float32, float64:
+		return fmt.Sprint(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			item, err := tomlValue(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items = append(items, item)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case reflect.Map:
+		keys := make([]string, 0, rv.Len())
+		values := make(map[string]interface{})
+		for _, k := range rv.MapKeys() {
+			key := fmt.Sprint(k.Interface())
+			keys = append(keys, key)
+			values[key] = rv.MapIndex(k).Interface()
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys))
+		for _, key := range keys {
+			item, err := tomlValue(values[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(key)+" = "+item)
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
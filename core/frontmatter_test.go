@@ -0,0 +1,64 @@
+package core_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderFrontMatter(t *testing.T) {
+	meta := &core.DocumentMeta{
+		Title:       "Release: v2",
+		DocumentID:  "doxcn123",
+		RevisionID:  42,
+		WikiPath:    []string{"Team", "Notes"},
+		UpdatedTime: time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+	}
+	fields := []string{"title", "document_id", "revision", "wiki_path", "owner", "updated_time"}
+	extra := map[string]interface{}{
+		"tags":   []interface{}{"go", "docs"},
+		"weight": 10,
+	}
+
+	yaml, err := core.RenderFrontMatter("yaml", fields, meta, extra)
+	assert.NoError(t, err)
+	assert.Equal(t, `---
+title: 'Release: v2'
+document_id: doxcn123
+revision: 42
+wiki_path:
+  - Team
+  - Notes
+updated_time: 2024-05-01T10:00:00+08:00
+tags:
+  - go
+  - docs
+weight: 10
+---
+
+`, yaml)
+
+	toml, err := core.RenderFrontMatter("toml", fields, meta, extra)
+	assert.NoError(t, err)
+	assert.Equal(t, `+++
+title = "Release: v2"
+document_id = "doxcn123"
+revision = 42
+wiki_path = ["Team", "Notes"]
+updated_time = 2024-05-01T10:00:00+08:00
+tags = ["go", "docs"]
+weight = 10
++++
+
+`, toml)
+
+	// Static keys override metadata of the same name
+	out, err := core.RenderFrontMatter("toml", []string{"title"}, meta, map[string]interface{}{"title": "Custom"})
+	assert.NoError(t, err)
+	assert.Equal(t, "+++\ntitle = \"Custom\"\n+++\n\n", out)
+
+	_, err = core.RenderFrontMatter("json", fields, meta, nil)
+	assert.Error(t, err)
+}
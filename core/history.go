@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SectionChange is a heading whose section differs between two revisions.
+type SectionChange struct {
+	Heading string
+	// Change is "added", "removed" or "modified"
+	Change string
+}
+
+// RevisionChanges lists the sections a revision of a document touched.
+type RevisionChanges struct {
+	Revision int64
+	Title    string
+	// Link points to the snapshot of the revision, relative to the changelog
+	Link    string
+	Changes []SectionChange
+	// Baseline marks the first exported revision when the one before it is
+	// unknown, so its content can't be compared
+	Baseline bool
+}
+
+type markdownSection struct {
+	key     string
+	heading string
+	content string
+}
+
+var atxHeadingRegexp = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// splitSections splits Markdown into the sections below each ATX heading,
+// ignoring fenced code. Text before the first heading belongs to a section
+// without heading. Repeated headings are keyed by their occurrence.
+func splitSections(markdown string) []markdownSection {
+	sections := []markdownSection{{}}
+	seen := make(map[string]int)
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+		} else if fence != "" && strings.HasPrefix(trimmed, fence) {
+			fence = ""
+		} else if m := atxHeadingRegexp.FindStringSubmatch(line); fence == "" && m != nil {
+			heading := m[2]
+			seen[heading]++
+			key := heading
+			if seen[heading] > 1 {
+				key = fmt.Sprintf("%s (%d)", heading, seen[heading])
+			}
+			sections = append(sections, markdownSection{key: key, heading: key})
+			continue
+		}
+		sections[len(sections)-1].content += line
+	}
+	return sections
+}
+
+// DiffSections compares two revisions of a Markdown document section by
+// section and lists the headings whose content was added, removed or
+// modified, in the order of the new revision followed by removed ones.
+func DiffSections(old, new string) []SectionChange {
+	oldSections := make(map[string]string)
+	for _, s := range splitSections(old) {
+		oldSections[s.key] = strings.TrimSpace(s.content)
+	}
+	changes := make([]SectionChange, 0)
+	seen := make(map[string]bool)
+	for _, s := range splitSections(new) {
+		seen[s.key] = true
+		content, ok := oldSections[s.key]
+		heading := s.heading
+		if heading == "" {
+			heading = "(preamble)"
+		}
+		switch {
+		case !ok:
+			changes = append(changes, SectionChange{heading, "added"})
+		case content != strings.TrimSpace(s.content):
+			changes = append(changes, SectionChange{heading, "modified"})
+		}
+	}
+	for _, s := range splitSections(old) {
+		if !seen[s.key] {
+			changes = append(changes, SectionChange{s.heading, "removed"})
+		}
+	}
+	return changes
+}
+
+// RenderChangelog renders the revisions of a document, newest first, with
+// the sections each of them touched.
+func RenderChangelog(title string, revisions []RevisionChanges) string {
+	buf := new(strings.Builder)
+	buf.WriteString(fmt.Sprintf("# Changelog: %s\n", title))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		r := revisions[i]
+		buf.WriteString(fmt.Sprintf("\n## [Revision %d](<%s>)\n\n", r.Revision, r.Link))
+		if r.Title != "" && r.Title != title {
+			buf.WriteString(fmt.Sprintf("Title: %s\n\n", r.Title))
+		}
+		if r.Baseline {
+			buf.WriteString("Baseline, earlier revisions are not exported.\n")
+			continue
+		}
+		if len(r.Changes) == 0 {
+			buf.WriteString("No section changes.\n")
+			continue
+		}
+		for _, c := range r.Changes {
+			buf.WriteString(fmt.Sprintf("- %s: %s\n", c.Change, c.Heading))
+		}
+	}
+	return buf.String()
+}
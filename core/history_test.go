@@ -0,0 +1,43 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSections(t *testing.T) {
+	v1 := "# Spec\n\nIntro\n\n## Scope\n\nAll\n\n## Risks\n\nNone\n"
+	v2 := "# Spec\n\nIntro\n\n## Scope\n\nSome\n\n```sh\n# not a heading\n```\n\n## Plan\n\nSoon\n"
+
+	assert.Equal(t, []core.SectionChange{
+		{Heading: "Spec", Change: "added"},
+		{Heading: "Scope", Change: "added"},
+		{Heading: "Risks", Change: "added"},
+	}, core.DiffSections("", v1))
+	assert.Equal(t, []core.SectionChange{
+		{Heading: "Scope", Change: "modified"},
+		{Heading: "Plan", Change: "added"},
+		{Heading: "Risks", Change: "removed"},
+	}, core.DiffSections(v1, v2))
+	assert.Empty(t, core.DiffSections(v2, v2))
+}
+
+func TestRenderChangelog(t *testing.T) {
+	changelog := core.RenderChangelog("Spec", []core.RevisionChanges{
+		{Revision: 1, Title: "Draft", Link: "spec.r1.md", Changes: []core.SectionChange{{Heading: "Draft", Change: "added"}}},
+		{Revision: 4, Title: "Spec", Link: "spec.r4.md"},
+	})
+	assert.Equal(t, "# Changelog: Spec\n\n"+
+		"## [Revision 4](<spec.r4.md>)\n\nNo section changes.\n\n"+
+		"## [Revision 1](<spec.r1.md>)\n\nTitle: Draft\n\n- added: Draft\n", changelog)
+}
+
+func TestRenderChangelogBaseline(t *testing.T) {
+	changelog := core.RenderChangelog("Spec", []core.RevisionChanges{
+		{Revision: 7, Title: "Spec", Link: "spec.r7.md", Baseline: true, Changes: []core.SectionChange{{Heading: "Scope", Change: "added"}}},
+	})
+	assert.Equal(t, "# Changelog: Spec\n\n"+
+		"## [Revision 7](<spec.r7.md>)\n\nBaseline, earlier revisions are not exported.\n", changelog)
+}
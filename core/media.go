@@ -0,0 +1,349 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// ProcessImage runs the media pipeline configured in OutputConfig over a
+// downloaded image: it caps the width, converts large images to WebP or JPEG
+// and strips EXIF metadata, keeping the orientation of JPEG photos. It returns the filename, rewritten to the new
+// extension when the format changed, and the resulting bytes. Images it can't
+// decode, such as GIF or SVG, are returned unchanged.
+func ProcessImage(filename string, data []byte, config OutputConfig) (string, []byte, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return filename, data, nil
+	}
+
+	convert := config.ImageFormat != "" && len(data) >= config.ImageConvertMinBytes
+	if config.ImageFormat == "jpeg" && ext != ".png" {
+		convert = false
+	}
+	orientation := 1
+	if ext != ".png" {
+		orientation = jpegOrientation(data)
+	}
+	resize := false
+	if config.ImageMaxWidth > 0 {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			width := cfg.Width
+			if orientation >= 5 {
+				// Rotated by a quarter turn, the height is shown as the width
+				width = cfg.Height
+			}
+			resize = width > config.ImageMaxWidth
+		}
+	}
+
+	if !convert && !resize {
+		if config.StripEXIF {
+			return filename, stripImageMetadata(ext, data), nil
+		}
+		return filename, data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return filename, data, nil
+	}
+	// The encoders write no EXIF, so the orientation goes into the pixels
+	img = applyOrientation(img, orientation)
+	if resize {
+		img = resizeToWidth(img, config.ImageMaxWidth)
+	}
+
+	format := strings.TrimPrefix(ext, ".")
+	if convert {
+		format = config.ImageFormat
+	}
+	buf := new(bytes.Buffer)
+	switch format {
+	case "webp":
+		err = nativewebp.Encode(buf, img, nil)
+		ext = ".webp"
+	case "jpeg", "jpg":
+		err = jpeg.Encode(buf, flattenImage(img), &jpeg.Options{Quality: config.ImageJPEGQuality})
+		ext = ".jpg"
+	default:
+		err = png.Encode(buf, img)
+		ext = ".png"
+	}
+	if err != nil {
+		return filename, data, err
+	}
+
+	// Keep the original when only a conversion was asked for and it didn't pay off
+	if !resize && buf.Len() >= len(data) {
+		if config.StripEXIF {
+			return filename, stripImageMetadata(filepath.Ext(filename), data), nil
+		}
+		return filename, data, nil
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext, buf.Bytes(), nil
+}
+
+// DataURI encodes an image as a base64 data URI, typed by its extension or,
+// failing that, by sniffing the content.
+func DataURI(filename string, data []byte) string {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// resizeToWidth scales img down to width, keeping the aspect ratio, by
+// averaging the source pixels covered by each destination pixel.
+func resizeToWidth(img image.Image, width int) image.Image {
+	src := image.NewNRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	height := sh * width / sw
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := src.NRGBAAt(sx, sy)
+					// Weight colors by alpha so transparent pixels don't bleed
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					b += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a), G: uint8(g / a), B: uint8(b / a), A: uint8(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// flattenImage composes img over white, since JPEG has no alpha channel.
+func flattenImage(img image.Image) image.Image {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// stripImageMetadata drops EXIF and text metadata without re-encoding: APP1
+// segments of a JPEG, eXIf and text chunks of a PNG. A JPEG keeps a minimal
+// EXIF segment holding only its orientation, so photos aren't shown rotated.
+// Data it can't walk is returned unchanged.
+func stripImageMetadata(ext string, data []byte) []byte {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return stripJPEGMetadata(data)
+	case ".png":
+		return stripPNGMetadata(data)
+	}
+	return data
+}
+
+func stripJPEGMetadata(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return data
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	if orientation := jpegOrientation(data); orientation != 1 {
+		out.Write(orientationSegment(orientation))
+	}
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return data
+		}
+		marker := data[i+1]
+		// Start of scan: the entropy-coded data and the rest follow as is
+		if marker == 0xDA {
+			out.Write(data[i:])
+			return out.Bytes()
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return data
+		}
+		if marker != 0xE1 {
+			out.Write(data[i : i+2+size])
+		}
+		i += 2 + size
+	}
+	return data
+}
+
+// jpegSegments calls fn with the marker and payload of each JPEG segment
+// before the start of scan, until fn returns false.
+func jpegSegments(data []byte, fn func(marker byte, payload []byte) bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+		size := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if size < 2 || i+2+size > len(data) {
+			return
+		}
+		if !fn(data[i+1], data[i+4:i+2+size]) {
+			return
+		}
+		i += 2 + size
+	}
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, from 1 to 8, or 1
+// when it has none.
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	jpegSegments(data, func(marker byte, payload []byte) bool {
+		if marker != 0xE1 || !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return true
+		}
+		tiff := payload[6:]
+		if len(tiff) < 8 {
+			return false
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return false
+		}
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return false
+		}
+		count := int(order.Uint16(tiff[ifd : ifd+2]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+				if v := int(order.Uint16(tiff[entry+8 : entry+10])); v >= 1 && v <= 8 {
+					orientation = v
+				}
+				break
+			}
+		}
+		return false
+	})
+	return orientation
+}
+
+// orientationSegment builds an APP1 segment whose EXIF holds only the
+// orientation tag.
+func orientationSegment(orientation int) []byte {
+	return []byte{
+		0xFF, 0xE1, 0x00, 0x22,
+		'E', 'x', 'i', 'f', 0x00, 0x00,
+		// Big-endian TIFF header, IFD0 right after it
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		// One entry: orientation, SHORT, count 1
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00,
+		// No next IFD
+		0x00, 0x00, 0x00, 0x00,
+	}
+}
+
+// applyOrientation turns img upright according to an EXIF orientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			// Source pixel shown at (x, y)
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+}
+
+func stripPNGMetadata(data []byte) []byte {
+	const header = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(header)) {
+		return data
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(header)
+	i := len(header)
+	for i+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + size
+		if size < 0 || end > len(data) {
+			return data
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes()
+}
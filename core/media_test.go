@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Noise keeps PNG from compressing better than JPEG
+			n := uint8((x*7919 + y*104729) ^ (x * y))
+			img.SetNRGBA(x, y, color.NRGBA{R: n, G: n * 3, B: n * 7, A: 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func TestProcessImage(t *testing.T) {
+	data := testPNG(t, 200, 100)
+
+	t.Run("disabled", func(t *testing.T) {
+		config := core.NewConfig("", "").Output
+		name, out, err := core.ProcessImage("static/a.png", data, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.png", name)
+		assert.Equal(t, data, out)
+	})
+
+	t.Run("max width", func(t *testing.T) {
+		config := core.NewConfig("", "").Output
+		config.ImageMaxWidth = 50
+		name, out, err := core.ProcessImage("static/a.png", data, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.png", name)
+		cfg, err := png.DecodeConfig(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, 50, cfg.Width)
+		assert.Equal(t, 25, cfg.Height)
+	})
+
+	t.Run("jpeg", func(t *testing.T) {
+		config := core.NewConfig("", "").Output
+		config.ImageFormat = "jpeg"
+		config.ImageConvertMinBytes = 0
+		name, out, err := core.ProcessImage("static/a.png", data, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.jpg", name)
+		_, err = jpeg.DecodeConfig(bytes.NewReader(out))
+		assert.NoError(t, err)
+	})
+
+	t.Run("webp", func(t *testing.T) {
+		config := core.NewConfig("", "").Output
+		config.ImageFormat = "webp"
+		config.ImageConvertMinBytes = 0
+		config.ImageMaxWidth = 100
+		name, out, err := core.ProcessImage("static/a.png", data, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.webp", name)
+		assert.Equal(t, "RIFF", string(out[:4]))
+		assert.Equal(t, "WEBP", string(out[8:12]))
+	})
+
+	t.Run("below threshold", func(t *testing.T) {
+		config := core.NewConfig("", "").Output
+		config.ImageFormat = "webp"
+		config.ImageConvertMinBytes = len(data) + 1
+		name, out, err := core.ProcessImage("static/a.png", data, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.png", name)
+		assert.Equal(t, data, out)
+	})
+
+	t.Run("strip exif", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+		raw := buf.Bytes()
+		exif := []byte{0xFF, 0xE1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0x00, 0x00}
+		withExif := append(append(append([]byte{}, raw[:2]...), exif...), raw[2:]...)
+
+		config := core.NewConfig("", "").Output
+		config.StripEXIF = true
+		name, out, err := core.ProcessImage("static/a.jpg", withExif, config)
+		assert.NoError(t, err)
+		assert.Equal(t, "static/a.jpg", name)
+		assert.Equal(t, raw, out)
+	})
+
+	t.Run("orientation", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		assert.NoError(t, jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 16, 8)), nil))
+		raw := buf.Bytes()
+		// Little-endian EXIF with the camera make and orientation 6 (rotate 90° clockwise)
+		exif := []byte{
+			0xFF, 0xE1, 0x00, 0x2E, 'E', 'x', 'i', 'f', 0x00, 0x00,
+			'I', 'I', 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00,
+			0x02, 0x00,
+			0x0F, 0x01, 0x02, 0x00, 0x04, 0x00, 0x00, 0x00, 'A', 'c', 'm', 'e',
+			0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		}
+		withExif := append(append(append([]byte{}, raw[:2]...), exif...), raw[2:]...)
+
+		config := core.NewConfig("", "").Output
+		config.StripEXIF = true
+		_, stripped, err := core.ProcessImage("static/a.jpg", withExif, config)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(stripped), "Acme")
+
+		// The orientation survives stripping and is applied when re-encoding
+		config.ImageMaxWidth = 4
+		_, out, err := core.ProcessImage("static/a.jpg", stripped, config)
+		assert.NoError(t, err)
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+		assert.NoError(t, err)
+		assert.Equal(t, 4, cfg.Width)
+		assert.Equal(t, 8, cfg.Height)
+	})
+}
+
+func TestDataURI(t *testing.T) {
+	assert.Equal(t, "data:image/png;base64,iVBORw==", core.DataURI("static/a.png", []byte{0x89, 'P', 'N', 'G'}))
+	// Unknown extensions are typed by content
+	assert.Equal(t, "data:image/png;base64,iVBORw0KGgo=", core.DataURI("static/a", []byte("\x89PNG\r\n\x1a\n")))
+
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	parser.SetSourceURL("https://example.feishu.cn/docx/doxcn123")
+	assert.Equal(t, "https://example.feishu.cn/space/api/box/stream/download/all/tok", parser.RemoteMediaURL("tok"))
+}
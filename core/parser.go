@@ -2,7 +2,10 @@ package core
 
 import (
 	"fmt"
+	"html"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Wsine/feishu2md/utils"
@@ -11,19 +14,100 @@ import (
 )
 
 type Parser struct {
-	useHTMLTags bool
-	ImgTokens   []string
+	useHTMLTags     bool
+	tableStyle      string
+	bitableMaxRows  int
+	syncedBlockMode string
+	gridStyle       string
+	imageAttributes bool
+	tocStyle        string
+	tocDepth        int
+	slugStyle       string
+	headingAnchors  string
+	deepHeadings    string
+	titleAsH1       bool
+	ImgTokens       []string
+	// BoardTokens lists whiteboards referenced like images, to be exported
+	BoardTokens []string
 	blockMap    map[string]*lark.DocxBlock
+	blockExts   map[string]*DocxBlockExt
+	// sourceURL is the Feishu URL of the document, used for fallback links
+	sourceURL string
+	// Unsupported lists blocks that were dropped from the output
+	Unsupported []UnsupportedBlock
 	// MentionUserMap maps Feishu OpenID -> display name for @mentions
 	MentionUserMap map[string]string
+	// bitableTables maps embedded bitable token -> pre-fetched table content
+	bitableTables map[string]*BitableTable
+	// syncedSources maps document ID -> document holding synced block sources
+	syncedSources map[string]*SyncedSource
+	// documentID is the ID of the document being parsed
+	documentID string
+	// syncedVisiting guards against synced blocks that reference each other
+	syncedVisiting map[string]bool
+	// Headings lists the headings of the document with their anchors
+	Headings []Heading
+	anchors  anchorSet
+	// minHeadingLevel is the shallowest heading level used in the document
+	minHeadingLevel int
+	// orderedNumbers caches the number of each ordered list item
+	orderedNumbers map[string]int
+	commentsMode   string
+	// comments maps comment ID -> comment, commentList keeps their order
+	comments    map[string]*Comment
+	commentList []*Comment
+	// commentAnchors maps text elements to the inline comments made on them
+	commentAnchors map[*lark.DocxTextElement][]string
+	// commentFootnotes numbers the comments referenced so far, in commentOrder
+	commentFootnotes map[string]int
+	commentOrder     []string
+}
+
+// UnsupportedBlock records a block the parser could not render.
+type UnsupportedBlock struct {
+	BlockType lark.DocxBlockType
+	BlockID   string
+}
+
+// BitableTable is the content of an embedded bitable block, fetched before
+// parsing since the parser itself does not talk to the API.
+type BitableTable struct {
+	Headers []string
+	Rows    [][]string
+	// CSVLink points to the full export of the table, relative to the document
+	CSVLink string
 }
 
 func NewParser(config OutputConfig) *Parser {
 	return &Parser{
-		useHTMLTags:    config.UseHTMLTags,
-		ImgTokens:      make([]string, 0),
-		blockMap:       make(map[string]*lark.DocxBlock),
-		MentionUserMap: make(map[string]string),
+		useHTMLTags:      config.UseHTMLTags,
+		tableStyle:       config.TableStyle,
+		bitableMaxRows:   config.BitableMaxRows,
+		syncedBlockMode:  config.SyncedBlockMode,
+		gridStyle:        config.GridStyle,
+		imageAttributes:  config.ImageAttributes,
+		tocStyle:         config.TOC,
+		tocDepth:         config.TOCDepth,
+		slugStyle:        config.SlugStyle,
+		headingAnchors:   config.HeadingAnchors,
+		deepHeadings:     config.DeepHeadings,
+		titleAsH1:        !config.OmitTitle,
+		Headings:         make([]Heading, 0),
+		anchors:          make(anchorSet),
+		orderedNumbers:   make(map[string]int),
+		commentsMode:     config.Comments,
+		comments:         make(map[string]*Comment),
+		commentAnchors:   make(map[*lark.DocxTextElement][]string),
+		commentFootnotes: make(map[string]int),
+		ImgTokens:        make([]string, 0),
+		BoardTokens:      make([]string, 0),
+		Unsupported:      make([]UnsupportedBlock, 0),
+		blockMap:         make(map[string]*lark.DocxBlock),
+		blockExts:        make(map[string]*DocxBlockExt),
+		MentionUserMap:   make(map[string]string),
+		bitableTables:    make(map[string]*BitableTable),
+		syncedSources:    make(map[string]*SyncedSource),
+		syncedVisiting:   make(map[string]bool),
 	}
 }
 
@@ -35,6 +119,72 @@ func (p *Parser) SetMentionUserMap(m map[string]string) {
 	p.MentionUserMap = m
 }
 
+// SetBlockExts sets the block payloads the lark SDK does not model, keyed by block ID.
+func (p *Parser) SetBlockExts(m map[string]*DocxBlockExt) {
+	if m == nil {
+		return
+	}
+	p.blockExts = m
+}
+
+// SetComments sets the comments of the document, rendered when the comments
+// option is on.
+func (p *Parser) SetComments(comments []*Comment) {
+	p.commentList = comments
+	for _, c := range comments {
+		p.comments[c.ID] = c
+	}
+}
+
+// SetSourceURL sets the Feishu URL of the document being parsed.
+func (p *Parser) SetSourceURL(url string) {
+	p.sourceURL = url
+}
+
+// SourceLink renders a placeholder link back to Feishu for content that
+// cannot be exported. An empty url falls back to the document itself.
+func (p *Parser) SourceLink(label, url string) string {
+	if url == "" {
+		url = p.sourceURL
+	}
+	if url == "" {
+		return fmt.Sprintf("*[%s]*", label)
+	}
+	return fmt.Sprintf("[%s](%s)", label, url)
+}
+
+// sourceHost returns the scheme and host of the document URL, if known.
+func (p *Parser) sourceHost() string {
+	return regexp.MustCompile(`^https?://[^/]+`).FindString(p.sourceURL)
+}
+
+// RemoteMediaURL returns the Feishu address of an image, which opens for
+// users signed in to the document's tenant.
+func (p *Parser) RemoteMediaURL(token string) string {
+	host := p.sourceHost()
+	if host == "" {
+		host = "https://www.feishu.cn"
+	}
+	return fmt.Sprintf("%s/space/api/box/stream/download/all/%s", host, token)
+}
+
+// SetSyncedSources sets the documents holding the sources of synced block
+// references, keyed by document ID.
+func (p *Parser) SetSyncedSources(m map[string]*SyncedSource) {
+	if m == nil {
+		return
+	}
+	p.syncedSources = m
+}
+
+// SetBitableTables sets the mapping of embedded bitable token -> table content.
+func (p *Parser) SetBitableTables(m map[string]*BitableTable) {
+	if m == nil {
+		return
+	}
+	p.bitableTables = m
+}
+
 // =============================================================
 // Parser utils
 // =============================================================
@@ -109,6 +259,31 @@ var DocxCodeLang2MdStr = map[lark.DocxCodeLanguage]string{
 	lark.DocxCodeLanguageYAML:         "yaml",
 }
 
+var DocxIframeType2Name = map[lark.DocxIframeComponentType]string{
+	lark.DocxIframeComponentTypeBilibili:      "哔哩哔哩",
+	lark.DocxIframeComponentTypeXigua:         "西瓜视频",
+	lark.DocxIframeComponentTypeYouku:         "优酷",
+	lark.DocxIframeComponentTypeAirtable:      "Airtable",
+	lark.DocxIframeComponentTypeBaiduMap:      "百度地图",
+	lark.DocxIframeComponentTypeGaodeMap:      "高德地图",
+	lark.DocxIframeComponentTypeTikTok:        "TikTok",
+	lark.DocxIframeComponentTypeFigma:         "Figma",
+	lark.DocxIframeComponentTypeModao:         "墨刀",
+	lark.DocxIframeComponentTypeCanva:         "Canva",
+	lark.DocxIframeComponentTypeCodePen:       "CodePen",
+	lark.DocxIframeComponentTypeFeishuWenjuan: "飞书问卷",
+	lark.DocxIframeComponentTypeJinshuju:      "金数据",
+	lark.DocxIframeComponentTypeGoogleMap:     "谷歌地图",
+	lark.DocxIframeComponentTypeYoutube:       "Youtube",
+}
+
+// DocxImageAlign2Name maps image block alignment to the HTML align attribute,
+// left alignment being the default and omitted.
+var DocxImageAlign2Name = map[int64]string{
+	2: "center",
+	3: "right",
+}
+
 func renderMarkdownTable(data [][]string) string {
 	builder := &strings.Builder{}
 	table := tablewriter.NewWriter(builder)
@@ -123,22 +298,46 @@ func renderMarkdownTable(data [][]string) string {
 	return builder.String()
 }
 
+// escapeMarkdownTableCell keeps a cell on a single line and stops pipes
+// from being read as column separators.
+func escapeMarkdownTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "<br/>")
+}
+
 // =============================================================
 // Parse the new version of document (docx)
 // =============================================================
 
 func (p *Parser) ParseDocxContent(doc *lark.DocxDocument, blocks []*lark.DocxBlock) string {
+	p.documentID = doc.DocumentID
 	for _, block := range blocks {
 		p.blockMap[block.BlockID] = block
 	}
 
+	for _, block := range blocks {
+		level := int(block.BlockType-lark.DocxBlockTypeHeading1) + 1
+		if level >= 1 && level <= 9 && (p.minHeadingLevel == 0 || level < p.minHeadingLevel) {
+			p.minHeadingLevel = level
+		}
+	}
+
+	if p.commentsMode == "footnotes" {
+		p.indexCommentAnchors(blocks)
+	}
+
 	entryBlock := p.blockMap[doc.DocumentID]
-	return p.ParseDocxBlock(entryBlock, 0)
+	markdown := p.ParseDocxBlock(entryBlock, 0)
+	if p.commentsMode == "footnotes" || p.commentsMode == "appendix" {
+		markdown += p.renderComments()
+	}
+	return markdown
 }
 
 func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 	buf := new(strings.Builder)
-	buf.WriteString(strings.Repeat("\t", indentLevel))
+	buf.WriteString(strings.Repeat("    ", indentLevel))
 	switch b.BlockType {
 	case lark.DocxBlockTypePage:
 		buf.WriteString(p.ParseDocxBlockPage(b))
@@ -188,14 +387,11 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 		// current todo content
 		buf.WriteString(p.ParseDocxBlockText(b.Todo))
 		// render nested checklist items (children)
-		for _, childId := range b.Children {
-			childBlock := p.blockMap[childId]
-			buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel+1))
-		}
+		buf.WriteString(p.parseListItemChildren(b, len("- ")))
 	case lark.DocxBlockTypeDivider:
 		buf.WriteString("---\n")
 	case lark.DocxBlockTypeImage:
-		buf.WriteString(p.ParseDocxBlockImage(b.Image))
+		buf.WriteString(p.ParseDocxBlockImage(b))
 	case lark.DocxBlockTypeTableCell:
 		buf.WriteString(p.ParseDocxBlockTableCell(b))
 	case lark.DocxBlockTypeTable:
@@ -204,7 +400,50 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 		buf.WriteString(p.ParseDocxBlockQuoteContainer(b))
 	case lark.DocxBlockTypeGrid:
 		buf.WriteString(p.ParseDocxBlockGrid(b, indentLevel))
+	case lark.DocxBlockTypeBitable:
+		buf.WriteString(p.ParseDocxBlockBitable(b.Bitable))
+	case DocxBlockTypeBoard:
+		buf.WriteString(p.ParseDocxBlockBoard(b))
+	case lark.DocxBlockTypeMindnote:
+		buf.WriteString(p.ParseDocxBlockMindnote(b.Mindnote))
+	case lark.DocxBlockTypeDiagram:
+		buf.WriteString(p.SourceLink("流程图", "") + "\n")
+	case lark.DocxBlockTypeIframe:
+		buf.WriteString(p.ParseDocxBlockIframe(b.Iframe))
+	case lark.DocxBlockTypeFile:
+		buf.WriteString(p.ParseDocxBlockFile(b.File))
+	case lark.DocxBlockTypeSheet:
+		buf.WriteString(p.ParseDocxBlockSheet(b.Sheet))
+	case lark.DocxBlockTypeChatCard:
+		label := "群聊卡片"
+		if b.ChatCard != nil && b.ChatCard.ChatID != "" {
+			label += " " + b.ChatCard.ChatID
+		}
+		buf.WriteString(p.SourceLink(label, "") + "\n")
+	case lark.DocxBlockTypeISV:
+		label := "小组件"
+		if b.ISV != nil && b.ISV.ComponentTypeID != "" {
+			label += " " + b.ISV.ComponentTypeID
+		}
+		buf.WriteString(p.SourceLink(label, "") + "\n")
+	case DocxBlockTypeTask, DocxBlockTypeOKR, DocxBlockTypeAddOns,
+		DocxBlockTypeJiraIssue, DocxBlockTypeLinkPreview:
+		buf.WriteString(p.ParseDocxBlockAddOn(b))
+	case DocxBlockTypeWikiCatalog, DocxBlockTypeSubPageList:
+		buf.WriteString(p.SourceLink("目录", "") + "\n")
+	case DocxBlockTypeSourceSynced:
+		buf.WriteString(p.ParseDocxBlockSourceSynced(b, indentLevel))
+	case DocxBlockTypeReferenceSynced:
+		buf.WriteString(p.ParseDocxBlockReferenceSynced(b, indentLevel))
+	case lark.DocxBlockTypeView, DocxBlockTypeAgenda, DocxBlockTypeAgendaItem,
+		DocxBlockTypeAgendaItemTitle, DocxBlockTypeAgendaContent:
+		// plain containers, only their children carry content
+		for _, childId := range b.Children {
+			childBlock := p.blockMap[childId]
+			buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel))
+		}
 	default:
+		buf.WriteString(p.ParseDocxBlockUnsupported(b))
 	}
 	return buf.String()
 }
@@ -212,19 +451,76 @@ func (p *Parser) ParseDocxBlock(b *lark.DocxBlock, indentLevel int) string {
 func (p *Parser) ParseDocxBlockPage(b *lark.DocxBlock) string {
 	buf := new(strings.Builder)
 
-	buf.WriteString("# ")
-	buf.WriteString(p.ParseDocxBlockText(b.Page))
-	buf.WriteString("\n")
+	if p.titleAsH1 {
+		buf.WriteString("# ")
+		buf.WriteString(p.ParseDocxBlockText(b.Page))
+		buf.WriteString("\n")
+	}
 
+	// Parse the body first so the TOC can list its headings
+	body := new(strings.Builder)
 	for _, childId := range b.Children {
 		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, 0))
-		buf.WriteString("\n")
+		body.WriteString(p.ParseDocxBlock(childBlock, 0))
+		body.WriteString("\n")
+	}
+
+	buf.WriteString(p.renderTOC())
+	buf.WriteString(body.String())
+
+	return buf.String()
+}
+
+// renderTOC renders the table of contents placed after the title: a nested
+// list of links for the "github" style, or a [TOC] marker for renderers
+// that build their own.
+func (p *Parser) renderTOC() string {
+	if p.tocStyle == "marker" {
+		return "[TOC]\n\n"
+	}
+	if p.tocStyle != "github" {
+		return ""
 	}
 
+	minLevel := 0
+	for _, h := range p.Headings {
+		if h.Level <= p.tocDepth && (minLevel == 0 || h.Level < minLevel) {
+			minLevel = h.Level
+		}
+	}
+	if minLevel == 0 {
+		return ""
+	}
+
+	buf := new(strings.Builder)
+	for _, h := range p.Headings {
+		if h.Level > p.tocDepth {
+			continue
+		}
+		label := strings.NewReplacer("[", "\\[", "]", "\\]").Replace(h.Text)
+		buf.WriteString(strings.Repeat("  ", h.Level-minLevel))
+		buf.WriteString(fmt.Sprintf("- [%s](#%s)\n", label, h.Anchor))
+	}
+	buf.WriteString("\n")
 	return buf.String()
 }
 
+// plainText returns the text of b without any markdown styling.
+func plainText(b *lark.DocxBlockText) string {
+	buf := new(strings.Builder)
+	for _, e := range b.Elements {
+		switch {
+		case e.TextRun != nil:
+			buf.WriteString(e.TextRun.Content)
+		case e.MentionDoc != nil:
+			buf.WriteString(e.MentionDoc.Title)
+		case e.Equation != nil:
+			buf.WriteString(e.Equation.Content)
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
 func (p *Parser) ParseDocxBlockText(b *lark.DocxBlockText) string {
 	buf := new(strings.Builder)
 	numElem := len(b.Elements)
@@ -294,6 +590,7 @@ func (p *Parser) ParseDocxTextElement(e *lark.DocxTextElement, inline bool) stri
 		}
 		buf.WriteString(symbol + strings.TrimSuffix(e.Equation.Content, "\n") + symbol)
 	}
+	buf.WriteString(p.commentFootnoteRefs(e))
 	return buf.String()
 }
 
@@ -344,28 +641,321 @@ func (p *Parser) ParseDocxTextElementTextRun(tr *lark.DocxTextElementTextRun) st
 func (p *Parser) ParseDocxBlockHeading(b *lark.DocxBlock, headingLevel int) string {
 	buf := new(strings.Builder)
 
-	buf.WriteString(strings.Repeat("#", headingLevel))
-	buf.WriteString(" ")
-
 	headingText := reflect.ValueOf(b).Elem().FieldByName(fmt.Sprintf("Heading%d", headingLevel))
-	buf.WriteString(p.ParseDocxBlockText(headingText.Interface().(*lark.DocxBlockText)))
+	text := headingText.Interface().(*lark.DocxBlockText)
+	content := p.ParseDocxBlockText(text)
+
+	level := p.outputHeadingLevel(headingLevel)
+	if level > 6 {
+		// Markdown has no deeper headings, render a bold paragraph instead
+		buf.WriteString("**")
+		buf.WriteString(strings.TrimRight(content, "\n"))
+		buf.WriteString("**\n")
+	} else {
+		heading := Heading{Level: level, Text: plainText(text)}
+		heading.Anchor = p.anchors.unique(Slugify(heading.Text, p.slugStyle))
+		p.Headings = append(p.Headings, heading)
+
+		switch p.headingAnchors {
+		case "attr":
+			content = strings.TrimRight(content, "\n") + fmt.Sprintf(" {#%s}\n", heading.Anchor)
+		case "html":
+			content = fmt.Sprintf(`<a id="%s"></a>`, heading.Anchor) + content
+		}
+		buf.WriteString(strings.Repeat("#", level))
+		buf.WriteString(" ")
+		buf.WriteString(content)
+	}
 
+	// Children of a folded heading are blocks of their own
 	for _, childId := range b.Children {
 		childBlock := p.blockMap[childId]
+		buf.WriteString("\n")
 		buf.WriteString(p.ParseDocxBlock(childBlock, 0))
 	}
 
 	return buf.String()
 }
 
-func (p *Parser) ParseDocxBlockImage(img *lark.DocxBlockImage) string {
+// outputHeadingLevel maps a Feishu heading level to the Markdown one. Levels
+// past 6 are clamped, unless the "bold" strategy renders them as bold text.
+// The "shift" strategy moves the shallowest heading of the document right
+// below the title and keeps the others relative to it.
+func (p *Parser) outputHeadingLevel(level int) int {
+	if p.deepHeadings == "shift" && p.minHeadingLevel > 0 {
+		top := 2
+		if !p.titleAsH1 {
+			top = 1
+		}
+		level = level - p.minHeadingLevel + top
+	}
+	if level > 6 && p.deepHeadings != "bold" {
+		level = 6
+	}
+	return level
+}
+
+func (p *Parser) ParseDocxBlockImage(b *lark.DocxBlock) string {
+	img := b.Image
 	buf := new(strings.Builder)
-	buf.WriteString(fmt.Sprintf("![](%s)", img.Token))
-	buf.WriteString("\n")
+
+	caption, align := "", int64(0)
+	if ext := p.blockExts[b.BlockID]; ext != nil && ext.Image != nil {
+		align = ext.Image.Align
+		if ext.Image.Caption != nil {
+			caption = strings.TrimSpace(ext.Image.Caption.Content)
+		}
+	}
+	alt := caption
+	if alt == "" {
+		alt = p.imageCaptionParagraph(b)
+	}
+
+	if p.useHTMLTags {
+		tag := fmt.Sprintf(`<img src="%s" alt="%s"`, img.Token, html.EscapeString(alt))
+		if img.Width > 0 && img.Height > 0 {
+			tag += fmt.Sprintf(` width="%d" height="%d"`, img.Width, img.Height)
+		}
+		tag += "/>"
+		if caption != "" {
+			tag += "<br/><em>" + html.EscapeString(caption) + "</em>"
+		}
+		if name, ok := DocxImageAlign2Name[align]; ok {
+			tag = fmt.Sprintf(`<p align="%s">%s</p>`, name, tag)
+		}
+		buf.WriteString(tag)
+		buf.WriteString("\n")
+	} else {
+		alt = strings.NewReplacer("[", "\\[", "]", "\\]").Replace(alt)
+		buf.WriteString(fmt.Sprintf("![%s](%s)", alt, img.Token))
+		if p.imageAttributes && img.Width > 0 && img.Height > 0 {
+			buf.WriteString(fmt.Sprintf("{width=%d height=%d}", img.Width, img.Height))
+		}
+		buf.WriteString("\n")
+		if caption != "" {
+			buf.WriteString("\n*" + caption + "*\n")
+		}
+	}
+
 	p.ImgTokens = append(p.ImgTokens, img.Token)
 	return buf.String()
 }
 
+// imageCaptionParagraph returns the text of the paragraph right after the
+// image when it reads like a caption, e.g. "图 1：..." or "Figure 2. ...".
+// The paragraph itself is still rendered as usual.
+func (p *Parser) imageCaptionParagraph(b *lark.DocxBlock) string {
+	parent := p.blockMap[b.ParentID]
+	if parent == nil {
+		return ""
+	}
+	for i, child := range parent.Children {
+		if child != b.BlockID || i+1 >= len(parent.Children) {
+			continue
+		}
+		next := p.blockMap[parent.Children[i+1]]
+		if next == nil || next.BlockType != lark.DocxBlockTypeText || next.Text == nil {
+			return ""
+		}
+		caption := plainText(next.Text)
+		if len([]rune(caption)) <= 120 && imageCaptionRegexp.MatchString(caption) {
+			return caption
+		}
+		return ""
+	}
+	return ""
+}
+
+var imageCaptionRegexp = regexp.MustCompile(`^(图|圖|Figure|Fig\.?)\s*\d+`)
+
+// ParseDocxBlockBoard references a whiteboard like an image; the caller
+// exports BoardTokens and replaces them with local files or SourceLink.
+func (p *Parser) ParseDocxBlockBoard(b *lark.DocxBlock) string {
+	ext := p.blockExts[b.BlockID]
+	if ext == nil || ext.Board == nil || ext.Board.Token == "" {
+		return p.SourceLink("画板", "") + "\n"
+	}
+	p.BoardTokens = append(p.BoardTokens, ext.Board.Token)
+	return fmt.Sprintf("![](%s)\n", ext.Board.Token)
+}
+
+// ParseDocxBlockMindnote links to the mindnote, as there is no API to
+// export it as an image.
+func (p *Parser) ParseDocxBlockMindnote(m *lark.DocxBlockMindnote) string {
+	url := ""
+	if host := p.sourceHost(); host != "" && m != nil && m.Token != "" {
+		url = host + "/mindnotes/" + m.Token
+	}
+	return p.SourceLink("思维笔记", url) + "\n"
+}
+
+func (p *Parser) ParseDocxBlockIframe(iframe *lark.DocxBlockIframe) string {
+	if iframe == nil || iframe.Component == nil || iframe.Component.URL == "" {
+		return p.SourceLink("内嵌网页", "") + "\n"
+	}
+	url := utils.UnescapeURL(iframe.Component.URL)
+	if p.useHTMLTags {
+		return fmt.Sprintf("<iframe src=\"%s\"></iframe>\n", html.EscapeString(url))
+	}
+	name := DocxIframeType2Name[iframe.Component.IframeType]
+	if name == "" {
+		name = url
+	}
+	return fmt.Sprintf("[%s](%s)\n", name, url)
+}
+
+func (p *Parser) ParseDocxBlockFile(f *lark.DocxBlockFile) string {
+	label := "附件"
+	if f != nil && f.Name != "" {
+		label = f.Name
+	}
+	return p.SourceLink(label, "") + "\n"
+}
+
+func (p *Parser) ParseDocxBlockSheet(sheet *lark.DocxBlockSheet) string {
+	url := ""
+	if host := p.sourceHost(); host != "" && sheet != nil && sheet.Token != "" {
+		// the block token is <spreadsheet token>_<sheet id>
+		token, sheetID, found := strings.Cut(sheet.Token, "_")
+		url = host + "/sheets/" + token
+		if found {
+			url += "?sheet=" + sheetID
+		}
+	}
+	return p.SourceLink("电子表格", url) + "\n"
+}
+
+// ParseDocxBlockAddOn renders task, OKR, Jira and other add-on blocks as a
+// single summary line linking back to Feishu.
+func (p *Parser) ParseDocxBlockAddOn(b *lark.DocxBlock) string {
+	ext := p.blockExts[b.BlockID]
+	if ext == nil {
+		ext = &DocxBlockExt{}
+	}
+	label, url := "", ""
+	switch b.BlockType {
+	case DocxBlockTypeTask:
+		label = "任务"
+		if ext.Task != nil && ext.Task.TaskID != "" {
+			url = "https://applink.feishu.cn/client/todo/detail?guid=" + ext.Task.TaskID
+		}
+	case DocxBlockTypeOKR:
+		label = "OKR"
+		if ext.OKR != nil && ext.OKR.PeriodNameZh != "" {
+			label += " " + ext.OKR.PeriodNameZh
+		}
+	case DocxBlockTypeJiraIssue:
+		label = "Jira"
+		if ext.JiraIssue != nil && ext.JiraIssue.Key != "" {
+			label += " " + ext.JiraIssue.Key
+		}
+	case DocxBlockTypeLinkPreview:
+		if ext.LinkPreview != nil && ext.LinkPreview.URL != "" {
+			url = utils.UnescapeURL(ext.LinkPreview.URL)
+			label = url
+		} else {
+			label = "链接"
+		}
+	default:
+		label = "小组件"
+		if ext.AddOns != nil && ext.AddOns.ComponentTypeID != "" {
+			label += " " + ext.AddOns.ComponentTypeID
+		}
+	}
+	return p.SourceLink(label, url) + "\n"
+}
+
+func (p *Parser) ParseDocxBlockSourceSynced(b *lark.DocxBlock, indentLevel int) string {
+	buf := new(strings.Builder)
+
+	for _, childId := range b.Children {
+		childBlock := p.blockMap[childId]
+		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel))
+	}
+	if p.syncedBlockMode == "transclusion" && syncedAnchorable(b) {
+		// Obsidian block ID so that references can embed this block
+		buf.WriteString(fmt.Sprintf("\n^%s\n", b.BlockID))
+	}
+
+	return buf.String()
+}
+
+// syncedAnchorable reports whether an Obsidian block ID after the content of
+// a synced source block covers all of it, which holds for a single child only:
+// after several paragraphs it would anchor the last one.
+func syncedAnchorable(source *lark.DocxBlock) bool {
+	return source != nil && len(source.Children) == 1
+}
+
+// ParseDocxBlockReferenceSynced expands a synced block reference from its
+// source block, which may live in a document set with SetSyncedSources.
+func (p *Parser) ParseDocxBlockReferenceSynced(b *lark.DocxBlock, indentLevel int) string {
+	ext := p.blockExts[b.BlockID]
+	if ext == nil || ext.ReferenceSynced == nil || ext.ReferenceSynced.SourceBlockID == "" {
+		return p.SourceLink("同步块", "") + "\n"
+	}
+	ref := ext.ReferenceSynced
+
+	source := p.blockMap[ref.SourceBlockID]
+	if p.syncedBlockMode == "transclusion" {
+		sameDocument := ref.SourceDocumentID == p.documentID || ref.SourceDocumentID == ""
+		if sameDocument && syncedAnchorable(source) {
+			return fmt.Sprintf("![[#^%s]]\n", ref.SourceBlockID)
+		}
+		if src := p.syncedSources[ref.SourceDocumentID]; !sameDocument && src != nil && src.Link != "" {
+			for _, sb := range src.Blocks {
+				if sb.BlockID == ref.SourceBlockID && syncedAnchorable(sb) {
+					return fmt.Sprintf("![[%s#^%s]]\n", src.Link, ref.SourceBlockID)
+				}
+			}
+		}
+		// The source document isn't exported or the block ID can't anchor
+		// the source, so its content is inlined
+	}
+
+	if src := p.syncedSources[ref.SourceDocumentID]; src != nil {
+		if source == nil {
+			for _, sb := range src.Blocks {
+				if _, ok := p.blockMap[sb.BlockID]; !ok {
+					p.blockMap[sb.BlockID] = sb
+				}
+			}
+			for id, e := range src.BlockExts {
+				if _, ok := p.blockExts[id]; !ok {
+					p.blockExts[id] = e
+				}
+			}
+			source = p.blockMap[ref.SourceBlockID]
+		}
+	}
+
+	if source == nil || p.syncedVisiting[source.BlockID] {
+		return p.SourceLink("同步块", "") + "\n"
+	}
+	p.syncedVisiting[source.BlockID] = true
+	defer delete(p.syncedVisiting, source.BlockID)
+
+	buf := new(strings.Builder)
+	for _, childId := range source.Children {
+		childBlock := p.blockMap[childId]
+		if childBlock == nil {
+			continue
+		}
+		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel))
+	}
+	return buf.String()
+}
+
+// ParseDocxBlockUnsupported keeps a trace of a block that cannot be rendered
+// and records it in Unsupported for reporting.
+func (p *Parser) ParseDocxBlockUnsupported(b *lark.DocxBlock) string {
+	p.Unsupported = append(p.Unsupported, UnsupportedBlock{
+		BlockType: b.BlockType,
+		BlockID:   b.BlockID,
+	})
+	return fmt.Sprintf("<!-- unsupported block: type=%d id=%s -->\n", b.BlockType, b.BlockID)
+}
+
 func (p *Parser) ParseDocxWhatever(body *lark.DocBody) string {
 	buf := new(strings.Builder)
 
@@ -377,27 +967,70 @@ func (p *Parser) ParseDocxBlockBullet(b *lark.DocxBlock, indentLevel int) string
 
 	buf.WriteString("- ")
 	buf.WriteString(p.ParseDocxBlockText(b.Bullet))
+	buf.WriteString(p.parseListItemChildren(b, len("- ")))
+
+	return buf.String()
+}
+
+func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) string {
+	buf := new(strings.Builder)
 
+	marker := fmt.Sprintf("%d. ", p.orderedNumber(b))
+	buf.WriteString(marker)
+	buf.WriteString(p.ParseDocxBlockText(b.Ordered))
+	buf.WriteString(p.parseListItemChildren(b, len(marker)))
+
+	return buf.String()
+}
+
+// parseListItemChildren renders the children of a list item indented with
+// width spaces, the column its content starts at, so that CommonMark nests
+// every line of them under the item.
+func (p *Parser) parseListItemChildren(b *lark.DocxBlock, width int) string {
+	buf := new(strings.Builder)
+	indent := strings.Repeat(" ", width)
 	for _, childId := range b.Children {
 		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel+1))
+		for _, line := range strings.SplitAfter(p.ParseDocxBlock(childBlock, 0), "\n") {
+			if strings.TrimSpace(line) != "" {
+				buf.WriteString(indent)
+			}
+			buf.WriteString(line)
+		}
 	}
-
 	return buf.String()
 }
 
-func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) string {
-	buf := new(strings.Builder)
+// orderedNumber returns the number of an ordered list item. Feishu stores a
+// sequence per item: a number restarts the list there and "auto" continues
+// from the previous ordered sibling, across code, image and quote blocks in
+// between but not across headings, text or anything else.
+// Without a sequence, consecutive ordered siblings are counted from 1.
+func (p *Parser) orderedNumber(b *lark.DocxBlock) int {
+	if n, ok := p.orderedNumbers[b.BlockID]; ok {
+		return n
+	}
+
+	sequence := ""
+	if ext := p.blockExts[b.BlockID]; ext != nil && ext.Ordered != nil && ext.Ordered.Style != nil {
+		sequence = ext.Ordered.Style.Sequence
+	}
 
-	// calculate order and indent level
-	parent := p.blockMap[b.ParentID]
 	order := 1
-	for idx, child := range parent.Children {
-		if child == b.BlockID {
+	if n, err := strconv.Atoi(sequence); err == nil {
+		order = n
+	} else if parent := p.blockMap[b.ParentID]; parent != nil {
+		for idx, child := range parent.Children {
+			if child != b.BlockID {
+				continue
+			}
 			for i := idx - 1; i >= 0; i-- {
-				if p.blockMap[parent.Children[i]].BlockType == lark.DocxBlockTypeOrdered {
-					order += 1
-				} else {
+				prev := p.blockMap[parent.Children[i]]
+				if prev != nil && prev.BlockType == lark.DocxBlockTypeOrdered {
+					order = p.orderedNumber(prev) + 1
+					break
+				}
+				if sequence != "auto" || !continuesOrderedList(prev) {
 					break
 				}
 			}
@@ -405,32 +1038,80 @@ func (p *Parser) ParseDocxBlockOrdered(b *lark.DocxBlock, indentLevel int) strin
 		}
 	}
 
-	buf.WriteString(fmt.Sprintf("%d. ", order))
-	buf.WriteString(p.ParseDocxBlockText(b.Ordered))
+	p.orderedNumbers[b.BlockID] = order
+	return order
+}
 
-	for _, childId := range b.Children {
-		childBlock := p.blockMap[childId]
-		buf.WriteString(p.ParseDocxBlock(childBlock, indentLevel+1))
+// continuesOrderedList reports whether an "auto" ordered item may continue
+// the numbering across the block.
+func continuesOrderedList(b *lark.DocxBlock) bool {
+	if b == nil {
+		return false
 	}
-
-	return buf.String()
+	switch b.BlockType {
+	case lark.DocxBlockTypeCode, lark.DocxBlockTypeImage, lark.DocxBlockTypeQuote, lark.DocxBlockTypeQuoteContainer:
+		return true
+	}
+	return false
 }
 
 func (p *Parser) ParseDocxBlockTableCell(b *lark.DocxBlock) string {
-	buf := new(strings.Builder)
+	return p.renderTableCellBlocks(b.Children)
+}
 
-	for _, child := range b.Children {
-		block := p.blockMap[child]
-		content := p.ParseDocxBlock(block, 0)
-		buf.WriteString(content + "<br/>")
+// renderTableCellBlocks renders cell content on a single line, since both a
+// pipe table row and an HTML block end at a line break. Lists and code keep
+// their structure as inline HTML.
+func (p *Parser) renderTableCellBlocks(children []string) string {
+	parts := make([]string, 0, len(children))
+	for i := 0; i < len(children); i++ {
+		block := p.blockMap[children[i]]
+		if block == nil {
+			continue
+		}
+		content := ""
+		switch block.BlockType {
+		case lark.DocxBlockTypeBullet, lark.DocxBlockTypeOrdered:
+			tag := "ul"
+			if block.BlockType == lark.DocxBlockTypeOrdered {
+				tag = "ol"
+			}
+			items := new(strings.Builder)
+			for ; i < len(children); i++ {
+				item := p.blockMap[children[i]]
+				if item == nil || item.BlockType != block.BlockType {
+					break
+				}
+				text := item.Bullet
+				if item.BlockType == lark.DocxBlockTypeOrdered {
+					text = item.Ordered
+				}
+				items.WriteString("<li>")
+				items.WriteString(strings.TrimSpace(p.ParseDocxBlockText(text)))
+				items.WriteString(p.renderTableCellBlocks(item.Children))
+				items.WriteString("</li>")
+			}
+			i--
+			content = fmt.Sprintf("<%s>%s</%s>", tag, items.String(), tag)
+		case lark.DocxBlockTypeCode:
+			code := strings.Trim(p.ParseDocxBlockText(block.Code), "\n")
+			lines := strings.Split(html.EscapeString(code), "\n")
+			content = "<code>" + strings.Join(lines, "<br/>") + "</code>"
+		default:
+			content = strings.TrimSpace(p.ParseDocxBlock(block, 0))
+			content = strings.ReplaceAll(content, "\n", "<br/>")
+		}
+		if content != "" {
+			parts = append(parts, content)
+		}
 	}
-
-	return buf.String()
+	return strings.Join(parts, "<br/>")
 }
 
 func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 	var rows [][]string
 	mergeInfoMap := map[int64]map[int64]*lark.DocxBlockTablePropertyMergeInfo{}
+	hasMerge := false
 
 	// 构建单元格合并信息的映射
 	if t.Property.MergeInfo != nil {
@@ -441,15 +1122,19 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 				mergeInfoMap[int64(rowIndex)] = map[int64]*lark.DocxBlockTablePropertyMergeInfo{}
 			}
 			mergeInfoMap[rowIndex][colIndex] = merge
+			if merge != nil && (merge.RowSpan > 1 || merge.ColSpan > 1) {
+				hasMerge = true
+			}
 		}
 	}
 
 	// 构建表格内容
 
 	for i, blockId := range t.Cells {
-		block := p.blockMap[blockId]
-		cellContent := p.ParseDocxBlock(block, 0)
-		cellContent = strings.ReplaceAll(cellContent, "\n", "")
+		cellContent := ""
+		if block := p.blockMap[blockId]; block != nil {
+			cellContent = p.renderTableCellBlocks(block.Children)
+		}
 		rowIndex := int64(i) / t.Property.ColumnSize
 		colIndex := int64(i) % t.Property.ColumnSize
 
@@ -464,6 +1149,10 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 		rows[rowIndex][colIndex] = cellContent
 	}
 
+	if len(rows) > 0 && (p.tableStyle == "gfm" || (p.tableStyle != "html" && !hasMerge)) {
+		return p.renderGFMTable(rows, mergeInfoMap)
+	}
+
 	// 渲染为 HTML 表格
 	buf := new(strings.Builder)
 	buf.WriteString("<table>\n")
@@ -515,6 +1204,32 @@ func (p *Parser) ParseDocxBlockTable(t *lark.DocxBlockTable) string {
 	return buf.String()
 }
 
+// renderGFMTable renders rows as a pipe table with the first row as header.
+// Cells covered by a merge are left empty, keeping content in the top-left cell.
+func (p *Parser) renderGFMTable(rows [][]string, mergeInfoMap map[int64]map[int64]*lark.DocxBlockTablePropertyMergeInfo) string {
+	covered := map[string]bool{}
+	data := make([][]string, 0, len(rows))
+	for rowIndex, row := range rows {
+		cells := make([]string, 0, len(row))
+		for colIndex, cellContent := range row {
+			if covered[fmt.Sprintf("%d-%d", rowIndex, colIndex)] {
+				cells = append(cells, "")
+				continue
+			}
+			if mergeInfo := mergeInfoMap[int64(rowIndex)][int64(colIndex)]; mergeInfo != nil {
+				for r := rowIndex; r < rowIndex+int(mergeInfo.RowSpan); r++ {
+					for c := colIndex; c < colIndex+int(mergeInfo.ColSpan); c++ {
+						covered[fmt.Sprintf("%d-%d", r, c)] = true
+					}
+				}
+			}
+			cells = append(cells, strings.ReplaceAll(cellContent, "|", "\\|"))
+		}
+		data = append(data, cells)
+	}
+	return renderMarkdownTable(data)
+}
+
 func (p *Parser) ParseDocxBlockQuoteContainer(b *lark.DocxBlock) string {
 	buf := new(strings.Builder)
 
@@ -528,14 +1243,151 @@ func (p *Parser) ParseDocxBlockQuoteContainer(b *lark.DocxBlock) string {
 }
 
 func (p *Parser) ParseDocxBlockGrid(b *lark.DocxBlock, indentLevel int) string {
+	switch p.gridStyle {
+	case "table":
+		return p.renderGridTable(b)
+	case "flex":
+		return p.renderGridFlex(b)
+	}
+
 	buf := new(strings.Builder)
 
+	for i, columnBlock := range p.gridColumns(b) {
+		if i > 0 {
+			buf.WriteString("\n---\n\n")
+		}
+		for _, child := range columnBlock.Children {
+			if block := p.blockMap[child]; block != nil {
+				buf.WriteString(p.ParseDocxBlock(block, indentLevel))
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// gridColumns returns the column blocks of a grid, skipping the columns
+// missing from the block list.
+func (p *Parser) gridColumns(b *lark.DocxBlock) []*lark.DocxBlock {
+	columns := make([]*lark.DocxBlock, 0, len(b.Children))
 	for _, child := range b.Children {
-		columnBlock := p.blockMap[child]
+		if column := p.blockMap[child]; column != nil {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// gridColumnWidths returns the width of each grid column in percent.
+func gridColumnWidths(columns []*lark.DocxBlock) []int64 {
+	ratios := make([]int64, len(columns))
+	total := int64(0)
+	for i, column := range columns {
+		ratios[i] = 1
+		if column.GridColumn != nil && column.GridColumn.WidthRatio > 0 {
+			ratios[i] = column.GridColumn.WidthRatio
+		}
+		total += ratios[i]
+	}
+	for i := range ratios {
+		ratios[i] = ratios[i] * 100 / total
+	}
+	return ratios
+}
+
+func (p *Parser) renderGridTable(b *lark.DocxBlock) string {
+	buf := new(strings.Builder)
+
+	columns := p.gridColumns(b)
+	widths := gridColumnWidths(columns)
+	buf.WriteString("<table>\n<tr>\n")
+	for i, columnBlock := range columns {
+		buf.WriteString(fmt.Sprintf(`<td width="%d%%">`, widths[i]))
+		buf.WriteString(p.renderTableCellBlocks(columnBlock.Children))
+		buf.WriteString("</td>\n")
+	}
+	buf.WriteString("</tr>\n</table>\n")
+
+	return buf.String()
+}
+
+func (p *Parser) renderGridFlex(b *lark.DocxBlock) string {
+	buf := new(strings.Builder)
+
+	columns := p.gridColumns(b)
+	widths := gridColumnWidths(columns)
+	buf.WriteString(`<div style="display: flex; gap: 1em;">`)
+	buf.WriteString("\n")
+	for i, columnBlock := range columns {
+		// Blank lines around the content let markdown render inside the div
+		buf.WriteString(fmt.Sprintf(`<div style="flex: %d;">`, widths[i]))
+		buf.WriteString("\n\n")
 		for _, child := range columnBlock.Children {
-			block := p.blockMap[child]
-			buf.WriteString(p.ParseDocxBlock(block, indentLevel))
+			if block := p.blockMap[child]; block != nil {
+				buf.WriteString(p.ParseDocxBlock(block, 0))
+			}
 		}
+		buf.WriteString("\n</div>\n")
+	}
+	buf.WriteString("</div>\n")
+
+	return buf.String()
+}
+
+func (p *Parser) ParseDocxBlockBitable(bt *lark.DocxBlockBitable) string {
+	buf := new(strings.Builder)
+
+	table := p.bitableTables[bt.Token]
+	if table == nil || len(table.Headers) == 0 {
+		buf.WriteString(fmt.Sprintf("<!-- bitable: %s -->\n", bt.Token))
+		return buf.String()
+	}
+
+	rows := table.Rows
+	if p.bitableMaxRows > 0 && len(rows) > p.bitableMaxRows {
+		rows = rows[:p.bitableMaxRows]
+	}
+
+	if p.useHTMLTags {
+		buf.WriteString("<table>\n<tr>\n")
+		for _, h := range table.Headers {
+			buf.WriteString(fmt.Sprintf("<th>%s</th>", html.EscapeString(h)))
+		}
+		buf.WriteString("</tr>\n")
+		for _, row := range rows {
+			buf.WriteString("<tr>\n")
+			for _, cell := range row {
+				cell = strings.ReplaceAll(html.EscapeString(cell), "\n", "<br/>")
+				buf.WriteString(fmt.Sprintf("<td>%s</td>", cell))
+			}
+			buf.WriteString("</tr>\n")
+		}
+		buf.WriteString("</table>\n")
+	} else {
+		data := make([][]string, 0, len(rows)+1)
+		header := make([]string, 0, len(table.Headers))
+		for _, h := range table.Headers {
+			header = append(header, escapeMarkdownTableCell(h))
+		}
+		data = append(data, header)
+		for _, row := range rows {
+			cells := make([]string, 0, len(row))
+			for _, cell := range row {
+				cells = append(cells, escapeMarkdownTableCell(cell))
+			}
+			data = append(data, cells)
+		}
+		buf.WriteString(renderMarkdownTable(data))
+	}
+
+	if len(rows) < len(table.Rows) {
+		buf.WriteString(fmt.Sprintf("\n仅显示前 %d 行，共 %d 行", len(rows), len(table.Rows)))
+		if table.CSVLink != "" {
+			buf.WriteString(fmt.Sprintf("，[完整数据 (CSV)](%s)", table.CSVLink))
+		}
+		buf.WriteString("\n")
+	} else if table.CSVLink != "" {
+		buf.WriteString(fmt.Sprintf("\n[完整数据 (CSV)](%s)\n", table.CSVLink))
 	}
 
 	return buf.String()
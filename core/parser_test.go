@@ -6,7 +6,9 @@ import (
 	"io"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/88250/lute"
 	"github.com/Wsine/feishu2md/core"
@@ -52,3 +54,429 @@ func TestParseDocxContent(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDocxBlockBitable(t *testing.T) {
+	config := core.NewConfig("", "").Output
+	config.BitableMaxRows = 1
+	parser := core.NewParser(config)
+	parser.SetBitableTables(map[string]*core.BitableTable{
+		"bascnApp_tblTable": {
+			Headers: []string{"Name", "Note"},
+			Rows:    [][]string{{"a|b", "line1\nline2"}, {"c", "d"}},
+			CSVLink: "doc/Table.csv",
+		},
+	})
+
+	md := parser.ParseDocxBlockBitable(&lark.DocxBlockBitable{Token: "bascnApp_tblTable"})
+	assert.Contains(t, md, `a\|b`)
+	assert.Contains(t, md, "line1<br/>line2")
+	assert.NotContains(t, md, "| c ")
+	assert.Contains(t, md, "[完整数据 (CSV)](doc/Table.csv)")
+
+	md = parser.ParseDocxBlockBitable(&lark.DocxBlockBitable{Token: "bascnOther_tblX"})
+	assert.Equal(t, "<!-- bitable: bascnOther_tblX -->\n", md)
+}
+
+func TestParseDocxBlockBoard(t *testing.T) {
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	parser.SetSourceURL("https://sample.feishu.cn/docx/doxcnToken")
+	parser.SetBlockExts(map[string]*core.DocxBlockExt{
+		"blk1": {Board: &core.DocxBlockBoard{Token: "boardToken"}},
+	})
+
+	md := parser.ParseDocxBlockBoard(&lark.DocxBlock{BlockID: "blk1", BlockType: core.DocxBlockTypeBoard})
+	assert.Equal(t, "![](boardToken)\n", md)
+	assert.Equal(t, []string{"boardToken"}, parser.BoardTokens)
+
+	md = parser.ParseDocxBlockBoard(&lark.DocxBlock{BlockID: "blk2", BlockType: core.DocxBlockTypeBoard})
+	assert.Equal(t, "[画板](https://sample.feishu.cn/docx/doxcnToken)\n", md)
+
+	md = parser.ParseDocxBlockMindnote(&lark.DocxBlockMindnote{Token: "mindToken"})
+	assert.Equal(t, "[思维笔记](https://sample.feishu.cn/mindnotes/mindToken)\n", md)
+}
+
+func TestParseDocxBlockFallbacks(t *testing.T) {
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	parser.SetSourceURL("https://sample.feishu.cn/docx/doxcnToken")
+	parser.SetBlockExts(map[string]*core.DocxBlockExt{
+		"task": {Task: &core.DocxBlockTask{TaskID: "guid1"}},
+	})
+
+	iframe := &lark.DocxBlock{BlockType: lark.DocxBlockTypeIframe, Iframe: &lark.DocxBlockIframe{
+		Component: &lark.DocxBlockIframeComponent{
+			IframeType: lark.DocxIframeComponentTypeFigma,
+			URL:        "https%3A%2F%2Fwww.figma.com%2Ffile%2Fabc",
+		},
+	}}
+	assert.Equal(t, "[Figma](https://www.figma.com/file/abc)\n", parser.ParseDocxBlock(iframe, 0))
+
+	task := &lark.DocxBlock{BlockID: "task", BlockType: core.DocxBlockTypeTask}
+	assert.Equal(t, "[任务](https://applink.feishu.cn/client/todo/detail?guid=guid1)\n", parser.ParseDocxBlock(task, 0))
+
+	unknown := &lark.DocxBlock{BlockID: "blk", BlockType: 777}
+	assert.Equal(t, "<!-- unsupported block: type=777 id=blk -->\n", parser.ParseDocxBlock(unknown, 0))
+	assert.Equal(t, []core.UnsupportedBlock{{BlockType: 777, BlockID: "blk"}}, parser.Unsupported)
+}
+
+func TestParseDocxBlockReferenceSynced(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"ref"}},
+		{BlockID: "ref", ParentID: "doc", BlockType: core.DocxBlockTypeReferenceSynced},
+	}
+	exts := map[string]*core.DocxBlockExt{
+		"ref": {ReferenceSynced: &core.DocxBlockReferenceSynced{SourceBlockID: "src", SourceDocumentID: "other"}},
+	}
+	sources := map[string]*core.SyncedSource{
+		"other": {Title: "Other", Blocks: []*lark.DocxBlock{
+			{BlockID: "src", BlockType: core.DocxBlockTypeSourceSynced, Children: []string{"txt"}},
+			{BlockID: "txt", ParentID: "src", BlockType: lark.DocxBlockTypeText, Text: text("shared")},
+		}},
+	}
+
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	parser.SetBlockExts(exts)
+	parser.SetSyncedSources(sources)
+	assert.Equal(t, "# Doc\n\nshared\n\n", parser.ParseDocxContent(doc, blocks))
+
+	// Transclusion falls back to the content while the source isn't exported
+	config := core.NewConfig("", "").Output
+	config.SyncedBlockMode = "transclusion"
+	parser = core.NewParser(config)
+	parser.SetBlockExts(exts)
+	parser.SetSyncedSources(sources)
+	assert.Equal(t, "# Doc\n\nshared\n\n", parser.ParseDocxContent(doc, blocks))
+
+	sources["other"].Link = "../Team/01-Other"
+	parser = core.NewParser(config)
+	parser.SetBlockExts(exts)
+	parser.SetSyncedSources(sources)
+	assert.Equal(t, "# Doc\n\n![[../Team/01-Other#^src]]\n\n", parser.ParseDocxContent(doc, blocks))
+
+	// A source in the same document is embedded from the current file
+	parser = core.NewParser(config)
+	parser.SetBlockExts(map[string]*core.DocxBlockExt{
+		"ref": {ReferenceSynced: &core.DocxBlockReferenceSynced{SourceBlockID: "src", SourceDocumentID: "doc"}},
+	})
+	md := parser.ParseDocxContent(doc, append([]*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"src", "ref"}},
+	}, append(blocks[1:], sources["other"].Blocks...)...))
+	assert.Contains(t, md, "shared\n\n^src\n")
+	assert.Contains(t, md, "![[#^src]]\n")
+
+	// A block ID would only anchor the last of several paragraphs, so a source
+	// with more children is inlined and gets no block ID
+	source := sources["other"].Blocks[0]
+	source.Children = []string{"txt", "txt2"}
+	sources["other"].Blocks = append(sources["other"].Blocks,
+		&lark.DocxBlock{BlockID: "txt2", ParentID: "src", BlockType: lark.DocxBlockTypeText, Text: text("more")})
+	parser = core.NewParser(config)
+	parser.SetBlockExts(exts)
+	parser.SetSyncedSources(sources)
+	md = parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "more")
+	assert.NotContains(t, md, "![[")
+	otherPage := &lark.DocxBlock{BlockID: "other", BlockType: lark.DocxBlockTypePage, Page: text("Other"), Children: []string{"src"}}
+	md = core.NewParser(config).ParseDocxContent(&lark.DocxDocument{DocumentID: "other"},
+		append([]*lark.DocxBlock{otherPage}, sources["other"].Blocks...))
+	assert.NotContains(t, md, "^src")
+}
+
+func TestParseDocxBlockTableStyle(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"tbl"}},
+		{BlockID: "c1", BlockType: lark.DocxBlockTypeTableCell, Children: []string{"t1"}},
+		{BlockID: "c2", BlockType: lark.DocxBlockTypeTableCell, Children: []string{"t2"}},
+		{BlockID: "c3", BlockType: lark.DocxBlockTypeTableCell, Children: []string{"b1", "b2"}},
+		{BlockID: "c4", BlockType: lark.DocxBlockTypeTableCell, Children: []string{"t3"}},
+		{BlockID: "t1", BlockType: lark.DocxBlockTypeText, Text: text("A")},
+		{BlockID: "t2", BlockType: lark.DocxBlockTypeText, Text: text("B")},
+		{BlockID: "t3", BlockType: lark.DocxBlockTypeText, Text: text("x|y")},
+		{BlockID: "b1", BlockType: lark.DocxBlockTypeBullet, Bullet: text("one")},
+		{BlockID: "b2", BlockType: lark.DocxBlockTypeBullet, Bullet: text("two")},
+	}
+	table := &lark.DocxBlock{BlockID: "tbl", BlockType: lark.DocxBlockTypeTable, Table: &lark.DocxBlockTable{
+		Cells:    []string{"c1", "c2", "c3", "c4"},
+		Property: &lark.DocxBlockTableProperty{RowSize: 2, ColumnSize: 2},
+	}}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	md := parser.ParseDocxContent(doc, append(blocks, table))
+	assert.NotContains(t, md, "<table>")
+	assert.Contains(t, md, "|-----")
+	assert.Contains(t, md, "<ul><li>one</li><li>two</li></ul>")
+	assert.Contains(t, md, `x\|y`)
+
+	table.Table.Property.MergeInfo = []*lark.DocxBlockTablePropertyMergeInfo{
+		{RowSpan: 1, ColSpan: 2}, {RowSpan: 1, ColSpan: 1},
+		{RowSpan: 1, ColSpan: 1}, {RowSpan: 1, ColSpan: 1},
+	}
+	parser = core.NewParser(core.NewConfig("", "").Output)
+	md = parser.ParseDocxContent(doc, append(blocks, table))
+	assert.Contains(t, md, `<td colspan="2">A</td>`)
+}
+
+func TestParseDocxBlockGrid(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"grid"}},
+		{BlockID: "grid", BlockType: lark.DocxBlockTypeGrid, Grid: &lark.DocxBlockGrid{ColumnSize: 2}, Children: []string{"col1", "col2"}},
+		{BlockID: "col1", BlockType: lark.DocxBlockTypeGridColumn, GridColumn: &lark.DocxBlockGridColumn{WidthRatio: 30}, Children: []string{"t1"}},
+		{BlockID: "col2", BlockType: lark.DocxBlockTypeGridColumn, GridColumn: &lark.DocxBlockGridColumn{WidthRatio: 70}, Children: []string{"t2"}},
+		{BlockID: "t1", BlockType: lark.DocxBlockTypeText, Text: text("before")},
+		{BlockID: "t2", BlockType: lark.DocxBlockTypeText, Text: text("after")},
+	}
+
+	tests := map[string]string{
+		"sequential": "before\n\n---\n\nafter",
+		"table":      `<td width="30%">before</td>`,
+		"flex":       `<div style="flex: 70;">`,
+	}
+	for style, want := range tests {
+		t.Run(style, func(t *testing.T) {
+			config := core.NewConfig("", "").Output
+			config.GridStyle = style
+			parser := core.NewParser(config)
+			md := parser.ParseDocxContent(doc, blocks)
+			assert.Contains(t, md, want)
+
+			// A column missing from the block list is skipped
+			missing := append([]*lark.DocxBlock{}, blocks...)
+			missing[1] = &lark.DocxBlock{BlockID: "grid", BlockType: lark.DocxBlockTypeGrid, Grid: &lark.DocxBlockGrid{ColumnSize: 3}, Children: []string{"col1", "gone", "col2"}}
+			assert.NotPanics(t, func() {
+				md = parser.ParseDocxContent(doc, missing)
+			})
+			assert.Contains(t, md, "after")
+		})
+	}
+}
+
+func TestParseDocxBlockImage(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"img1", "img2", "cap"}},
+		{BlockID: "img1", ParentID: "doc", BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{Token: "tok1", Width: 640, Height: 480}},
+		{BlockID: "img2", ParentID: "doc", BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{Token: "tok2"}},
+		{BlockID: "cap", ParentID: "doc", BlockType: lark.DocxBlockTypeText, Text: text("图 2：架构")},
+	}
+	exts := map[string]*core.DocxBlockExt{
+		"img1": {Image: &core.DocxBlockImageExt{Align: 2, Caption: &core.DocxImageCaption{Content: "登录页"}}},
+	}
+
+	config := core.NewConfig("", "").Output
+	config.ImageAttributes = true
+	parser := core.NewParser(config)
+	parser.SetBlockExts(exts)
+	md := parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "![登录页](tok1){width=640 height=480}")
+	assert.Contains(t, md, "*登录页*")
+	assert.Contains(t, md, "![图 2：架构](tok2)")
+	assert.Equal(t, []string{"tok1", "tok2"}, parser.ImgTokens)
+
+	config.UseHTMLTags = true
+	parser = core.NewParser(config)
+	parser.SetBlockExts(exts)
+	md = parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, `<p align="center"><img src="tok1" alt="登录页" width="640" height="480"/><br/><em>登录页</em></p>`)
+}
+
+func TestParseDocxBlockTOC(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Spec"), Children: []string{"h1", "h2", "h3", "h4"}},
+		{BlockID: "h1", BlockType: lark.DocxBlockTypeHeading2, Heading2: text("安装 Guide")},
+		{BlockID: "h2", BlockType: lark.DocxBlockTypeHeading3, Heading3: text("配置")},
+		{BlockID: "h3", BlockType: lark.DocxBlockTypeHeading3, Heading3: text("配置")},
+		{BlockID: "h4", BlockType: lark.DocxBlockTypeHeading4, Heading4: text("Too deep")},
+	}
+
+	config := core.NewConfig("", "").Output
+	config.TOC = "github"
+	parser := core.NewParser(config)
+	md := parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "# Spec\n\n- [安装 Guide](#安装-guide)\n  - [配置](#配置)\n  - [配置](#配置-1)\n\n## 安装 Guide")
+	assert.NotContains(t, md, "(#too-deep)")
+
+	config.TOC = "marker"
+	config.SlugStyle = "pinyin"
+	config.HeadingAnchors = "attr"
+	parser = core.NewParser(config)
+	md = parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "# Spec\n\n[TOC]\n\n## 安装 Guide {#an-zhuang-guide}")
+	assert.Contains(t, md, "### 配置 {#pei-zhi-1}")
+	assert.Equal(t, "too-deep", parser.Headings[3].Anchor)
+
+	assert.Equal(t, "v20-release", core.Slugify("v2.0 Release!", "github"))
+	assert.Equal(t, "v2-0-release", core.Slugify("v2.0 Release!", "hugo"))
+	assert.Equal(t, "shi-yong-shuo-ming-v2", core.Slugify("使用说明 (v2)", "pinyin"))
+}
+
+func TestParseDocxBlockDeepHeadings(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"h3", "h9"}},
+		{BlockID: "h3", BlockType: lark.DocxBlockTypeHeading3, Heading3: text("Top"), Children: []string{"t"}},
+		{BlockID: "t", BlockType: lark.DocxBlockTypeText, Text: text("folded")},
+		{BlockID: "h9", BlockType: lark.DocxBlockTypeHeading9, Heading9: text("Deep")},
+	}
+
+	tests := []struct {
+		strategy  string
+		omitTitle bool
+		want      string
+	}{
+		{"clamp", false, "# Doc\n\n### Top\n\nfolded\n\n###### Deep\n"},
+		{"bold", false, "# Doc\n\n### Top\n\nfolded\n\n**Deep**\n"},
+		{"shift", false, "# Doc\n\n## Top\n\nfolded\n\n###### Deep\n"},
+		{"shift", true, "# Top\n\nfolded\n\n###### Deep\n"},
+	}
+	for _, tt := range tests {
+		config := core.NewConfig("", "").Output
+		config.DeepHeadings = tt.strategy
+		config.OmitTitle = tt.omitTitle
+		parser := core.NewParser(config)
+		md := parser.ParseDocxContent(doc, blocks)
+		assert.Equal(t, tt.want, strings.TrimRight(md, "\n")+"\n", tt.strategy)
+	}
+}
+
+func TestParseDocxBlockOrderedSequence(t *testing.T) {
+	text := func(s string) *lark.DocxBlockText {
+		return &lark.DocxBlockText{Elements: []*lark.DocxTextElement{
+			{TextRun: &lark.DocxTextElementTextRun{Content: s}},
+		}}
+	}
+	sequence := func(s string) *core.DocxBlockExt {
+		return &core.DocxBlockExt{Ordered: &core.DocxBlockOrderedExt{
+			Style: &core.DocxOrderedStyle{Sequence: s},
+		}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"o1", "o2", "p", "o3"}},
+		{BlockID: "o1", ParentID: "doc", BlockType: lark.DocxBlockTypeOrdered, Ordered: text("five"), Children: []string{"b"}},
+		{BlockID: "b", ParentID: "o1", BlockType: lark.DocxBlockTypeBullet, Bullet: text("nested")},
+		{BlockID: "o2", ParentID: "doc", BlockType: lark.DocxBlockTypeOrdered, Ordered: text("six")},
+		{BlockID: "p", ParentID: "doc", BlockType: lark.DocxBlockTypeCode, Code: &lark.DocxBlockText{
+			Style:    &lark.DocxTextStyle{Language: lark.DocxCodeLanguageGo},
+			Elements: text("interruption").Elements,
+		}},
+		{BlockID: "o3", ParentID: "doc", BlockType: lark.DocxBlockTypeOrdered, Ordered: text("seven")},
+	}
+
+	parser := core.NewParser(core.NewConfig("", "").Output)
+	parser.SetBlockExts(map[string]*core.DocxBlockExt{
+		"o1": sequence("5"),
+		"o2": sequence("auto"),
+		"o3": sequence("auto"),
+	})
+	md := parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "5. five\n   - nested\n")
+	assert.Contains(t, md, "6. six\n")
+	assert.Contains(t, md, "7. seven\n")
+	assert.NotContains(t, md, "\t")
+
+	// Without sequence info an interruption restarts the numbering
+	parser = core.NewParser(core.NewConfig("", "").Output)
+	md = parser.ParseDocxContent(doc, blocks)
+	assert.Contains(t, md, "1. five\n")
+	assert.Contains(t, md, "2. six\n")
+	assert.Contains(t, md, "1. seven\n")
+
+	// A heading or text between the lists starts a new list even with "auto"
+	for _, between := range []*lark.DocxBlock{
+		{BlockID: "h", ParentID: "page", BlockType: lark.DocxBlockTypeHeading1, Heading1: text("Section")},
+		{BlockID: "h", ParentID: "page", BlockType: lark.DocxBlockTypeText, Text: text("Section")},
+	} {
+		parser = core.NewParser(core.NewConfig("", "").Output)
+		parser.SetBlockExts(map[string]*core.DocxBlockExt{
+			"l1": sequence("1"),
+			"l2": sequence("auto"),
+		})
+		md = parser.ParseDocxContent(&lark.DocxDocument{DocumentID: "page"}, []*lark.DocxBlock{
+			{BlockID: "page", BlockType: lark.DocxBlockTypePage, Page: text("Doc"), Children: []string{"l1", "h", "l2"}},
+			{BlockID: "l1", ParentID: "page", BlockType: lark.DocxBlockTypeOrdered, Ordered: text("first")},
+			between,
+			{BlockID: "l2", ParentID: "page", BlockType: lark.DocxBlockTypeOrdered, Ordered: text("again")},
+		})
+		assert.Contains(t, md, "1. first\n")
+		assert.Contains(t, md, "1. again\n")
+	}
+}
+
+func TestParseDocxComments(t *testing.T) {
+	run := func(s string) *lark.DocxTextElement {
+		return &lark.DocxTextElement{TextRun: &lark.DocxTextElementTextRun{Content: s}}
+	}
+	doc := &lark.DocxDocument{DocumentID: "doc"}
+	blocks := func() []*lark.DocxBlock {
+		return []*lark.DocxBlock{
+			{BlockID: "doc", BlockType: lark.DocxBlockTypePage,
+				Page: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{run("Doc")}}, Children: []string{"t"}},
+			{BlockID: "t", BlockType: lark.DocxBlockTypeText,
+				Text: &lark.DocxBlockText{Elements: []*lark.DocxTextElement{run("Ship "), run("on Friday"), run(".")}}},
+		}
+	}
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	comments := []*core.Comment{
+		{ID: "c1", Quote: "on Friday", Replies: []*core.CommentReply{
+			{AuthorID: "ou_a", Author: "Alice", CreatedTime: at, Text: "Too early?"},
+			{AuthorID: "ou_b", Author: "Bob", CreatedTime: at, Text: "Fine."},
+		}},
+		{ID: "c2", Whole: true, Solved: true, Replies: []*core.CommentReply{
+			{AuthorID: "ou_b", Text: "LGTM"},
+		}},
+	}
+	exts := map[string]*core.DocxBlockExt{
+		"t": {Comments: &core.DocxBlockComments{Elements: [][]string{nil, {"c1"}, nil}}},
+	}
+
+	config := core.NewConfig("", "").Output
+	config.Comments = "footnotes"
+	parser := core.NewParser(config)
+	parser.SetBlockExts(exts)
+	parser.SetComments(comments)
+	md := parser.ParseDocxContent(doc, blocks())
+	assert.Contains(t, md, "Ship on Friday[^1].\n")
+	assert.Contains(t, md, "[^1]: **Alice** (2024-03-01 09:30): Too early?\n\n    **Bob** (2024-03-01 09:30): Fine.\n")
+	assert.Contains(t, md, "## Comments\n\n- **ou_b**: LGTM\n\n*(resolved)*\n")
+
+	config.Comments = "appendix"
+	parser = core.NewParser(config)
+	parser.SetBlockExts(exts)
+	parser.SetComments(comments)
+	md = parser.ParseDocxContent(doc, blocks())
+	assert.NotContains(t, md, "[^")
+	assert.Contains(t, md, "## Comments\n\n> on Friday\n\n- **Alice** (2024-03-01 09:30): Too early?\n- **Bob**")
+}
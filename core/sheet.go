@@ -0,0 +1,63 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SheetCellText renders a cell value of the sheets API as plain text: rich
+// text segments are joined, links and mentions keep their text.
+func SheetCellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		var sb strings.Builder
+		for _, segment := range v {
+			sb.WriteString(SheetCellText(segment))
+		}
+		return sb.String()
+	case map[string]interface{}:
+		if text, ok := v["text"].(string); ok && text != "" {
+			return text
+		}
+		if link, ok := v["link"].(string); ok {
+			return link
+		}
+		if values, ok := v["values"].([]interface{}); ok {
+			texts := make([]string, 0, len(values))
+			for _, value := range values {
+				texts = append(texts, SheetCellText(value))
+			}
+			return strings.Join(texts, ",")
+		}
+	}
+	return ""
+}
+
+// trimSheetValues drops the empty rows and columns padding the used range.
+func trimSheetValues(s *SheetValues) *SheetValues {
+	width := 0
+	height := 0
+	for i, row := range s.Rows {
+		for j, cell := range row {
+			if cell != "" {
+				width = max(width, j+1)
+				height = i + 1
+			}
+		}
+	}
+	s.Rows = s.Rows[:height]
+	for i, row := range s.Rows {
+		if len(row) > width {
+			s.Rows[i] = row[:width]
+		}
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSheetCellText(t *testing.T) {
+	assert.Equal(t, "", core.SheetCellText(nil))
+	assert.Equal(t, "-1.5", core.SheetCellText(-1.5))
+	assert.Equal(t, "42", core.SheetCellText(float64(42)))
+	assert.Equal(t, "site", core.SheetCellText(map[string]interface{}{"type": "url", "text": "site", "link": "https://a"}))
+	assert.Equal(t, "a,b", core.SheetCellText(map[string]interface{}{"type": "multipleValue", "values": []interface{}{"a", "b"}}))
+	assert.Equal(t, "bold plain", core.SheetCellText([]interface{}{
+		map[string]interface{}{"type": "text", "text": "bold "},
+		map[string]interface{}{"type": "text", "text": "plain"},
+	}))
+}
+
+func fakeOpenAPI(routes map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+			return
+		}
+		data, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if b, ok := data.([]byte); ok {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(b)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+	}))
+}
+
+func TestGetSheetValues(t *testing.T) {
+	server := fakeOpenAPI(map[string]interface{}{
+		"/open-apis/sheets/v3/spreadsheets/sht1/sheets/query": map[string]interface{}{
+			"sheets": []map[string]interface{}{
+				{"sheet_id": "s1", "title": "Budget", "resource_type": "sheet"},
+				{"sheet_id": "s2", "title": "Tasks", "resource_type": "bitable"},
+			},
+		},
+		"/open-apis/sheets/v2/spreadsheets/sht1/values/s1": map[string]interface{}{
+			"valueRange": map[string]interface{}{"values": [][]interface{}{
+				{"item", "amount", nil},
+				{"rent", -1200.5, nil},
+				{nil, nil, nil},
+			}},
+		},
+	})
+	defer server.Close()
+
+	client := core.NewClientWithBaseURL("app", "secret", server.URL)
+	sheets, err := client.GetSheetValues(context.Background(), "sht1")
+	assert.NoError(t, err)
+	assert.Len(t, sheets, 1)
+	assert.Equal(t, "Budget", sheets[0].Title)
+	assert.Equal(t, [][]string{{"item", "amount"}, {"rent", "-1200.5"}}, sheets[0].Rows)
+}
+
+func TestExportDriveFile(t *testing.T) {
+	server := fakeOpenAPI(map[string]interface{}{
+		"/open-apis/drive/v1/export_tasks":                  map[string]interface{}{"ticket": "tk"},
+		"/open-apis/drive/v1/export_tasks/tk":               map[string]interface{}{"result": map[string]interface{}{"job_status": 0, "file_token": "f1"}},
+		"/open-apis/drive/v1/export_tasks/file/f1/download": []byte("xlsx"),
+	})
+	defer server.Close()
+	core.ExportPollInterval = time.Millisecond
+
+	client := core.NewClientWithBaseURL("app", "secret", server.URL)
+	filename := filepath.Join(t.TempDir(), "out", "Budget.xlsx")
+	assert.NoError(t, client.ExportDriveFile(context.Background(), "sht1", "sheet", "xlsx", filename))
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("xlsx"), data)
+
+	// Polling stops when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	core.ExportPollInterval = time.Hour
+	defer func() { core.ExportPollInterval = time.Second }()
+	assert.ErrorIs(t, client.ExportDriveFile(ctx, "sht1", "sheet", "xlsx", filename), context.DeadlineExceeded)
+}
@@ -0,0 +1,234 @@
+package core
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/chyroc/lark"
+)
+
+// SyncAction is what a two-way sync does with a document.
+type SyncAction int
+
+const (
+	// SyncUpToDate: neither side changed since the last sync
+	SyncUpToDate SyncAction = iota
+	// SyncPull: only the remote document changed
+	SyncPull
+	// SyncPush: only the local file changed
+	SyncPush
+	// SyncConflict: both sides changed and have to be compared
+	SyncConflict
+	// SyncConflictPending: the conflict file of an earlier sync is still there
+	SyncConflictPending
+	// SyncSkipPull: only the remote document changed, but the sync only pushes
+	SyncSkipPull
+)
+
+// SyncSides describes both sides of a two-way synced document together with
+// the state recorded at the last sync.
+type SyncSides struct {
+	// Synced is false before the first two-way sync
+	Synced bool
+	// RevisionID is the remote revision at the last sync
+	RevisionID int64
+	// FileHash is the hash of the local file after the last sync
+	FileHash string
+	// ConflictRevision is the remote revision when a conflict file was
+	// written, 0 when there was no conflict
+	ConflictRevision   int64
+	ConflictFileExists bool
+
+	RemoteRevision int64
+	LocalExists    bool
+	LocalHash      string
+	// PushOnly is set when local changes are pushed but remote ones not pulled
+	PushOnly bool
+}
+
+// TwoWaySyncAction decides how to sync a document: the side that changed
+// since the last sync wins, and changes on both sides are a conflict. Once
+// the conflict file is deleted, the local file is taken to have merged the
+// remote revision the conflict was written for.
+func TwoWaySyncAction(s SyncSides) SyncAction {
+	if s.Synced && s.ConflictRevision != 0 && s.ConflictFileExists {
+		return SyncConflictPending
+	}
+	localChanged := s.LocalExists && (!s.Synced || s.LocalHash != s.FileHash)
+	remoteChanged := !s.Synced || s.RemoteRevision != s.RevisionID
+	if s.Synced && s.ConflictRevision != 0 && s.RemoteRevision == s.ConflictRevision {
+		remoteChanged = false
+	}
+
+	switch {
+	case !localChanged && !remoteChanged:
+		return SyncUpToDate
+	case localChanged && remoteChanged:
+		return SyncConflict
+	case localChanged:
+		return SyncPush
+	case s.PushOnly && s.LocalExists:
+		return SyncSkipPull
+	}
+	return SyncPull
+}
+
+// pushableBlockTypes are the block types MarkdownToDocxBlocks creates, which
+// survive an export and a push back.
+var pushableBlockTypes = map[lark.DocxBlockType]bool{
+	lark.DocxBlockTypePage:           true,
+	lark.DocxBlockTypeText:           true,
+	lark.DocxBlockTypeHeading1:       true,
+	lark.DocxBlockTypeHeading2:       true,
+	lark.DocxBlockTypeHeading3:       true,
+	lark.DocxBlockTypeHeading4:       true,
+	lark.DocxBlockTypeHeading5:       true,
+	lark.DocxBlockTypeHeading6:       true,
+	lark.DocxBlockTypeHeading7:       true,
+	lark.DocxBlockTypeHeading8:       true,
+	lark.DocxBlockTypeHeading9:       true,
+	lark.DocxBlockTypeBullet:         true,
+	lark.DocxBlockTypeOrdered:        true,
+	lark.DocxBlockTypeCode:           true,
+	lark.DocxBlockTypeQuote:          true,
+	lark.DocxBlockTypeTodo:           true,
+	lark.DocxBlockTypeDivider:        true,
+	lark.DocxBlockTypeImage:          true,
+	lark.DocxBlockTypeTable:          true,
+	lark.DocxBlockTypeTableCell:      true,
+	lark.DocxBlockTypeQuoteContainer: true,
+}
+
+// UnpushableBlocks lists the blocks of a document that replacing its content
+// with Markdown would lose: block types the upload can't create, such as
+// whiteboards, bitables, sheets or synced blocks, tables with merged cells
+// and text with comments anchored on it.
+func UnpushableBlocks(blocks []*lark.DocxBlock, exts map[string]*DocxBlockExt) []UnsupportedBlock {
+	unpushable := make([]UnsupportedBlock, 0)
+	for _, b := range blocks {
+		lost := !pushableBlockTypes[b.BlockType]
+		if b.BlockType == lark.DocxBlockTypeTable && b.Table != nil && b.Table.Property != nil {
+			for _, merge := range b.Table.Property.MergeInfo {
+				if merge != nil && (merge.RowSpan > 1 || merge.ColSpan > 1) {
+					lost = true
+				}
+			}
+		}
+		if ext := exts[b.BlockID]; ext != nil && ext.Comments != nil {
+			for _, ids := range ext.Comments.Elements {
+				if len(ids) > 0 {
+					lost = true
+				}
+			}
+		}
+		if lost {
+			unpushable = append(unpushable, UnsupportedBlock{BlockType: b.BlockType, BlockID: b.BlockID})
+		}
+	}
+	return unpushable
+}
+
+var (
+	tocLinkLine       = regexp.MustCompile(`^\s*- \[.*\]\(#[^)]*\)$`)
+	headingAttrAnchor = regexp.MustCompile(`^(#{1,6} .*?) \{#[^}]*\}$`)
+	headingHTMLAnchor = regexp.MustCompile(`^(#{1,6} )<a id="[^"]*"></a>`)
+	obsidianBlockID   = regexp.MustCompile(`^\^[A-Za-z0-9_-]+$`)
+	obsidianEmbed     = regexp.MustCompile(`^!\[\[[^\]]*#\^[^\]]+\]\]$`)
+	commentFootnote   = regexp.MustCompile(`\[\^\d+\]`)
+	commentDefinition = regexp.MustCompile(`^\[\^\d+\]: `)
+	bitableRowsNote   = regexp.MustCompile(`^仅显示前 \d+ 行，共 \d+ 行`)
+)
+
+// StripExportSyntax removes from an exported document what the exporter adds
+// under config but isn't content of the Feishu document, so that pushing the
+// file back doesn't turn it into text: the table of contents, heading anchors,
+// Obsidian block IDs and embeds of synced blocks, comment footnotes and
+// appendix, notes and CSV links of bitables and unsupported block markers.
+func StripExportSyntax(markdown string, config OutputConfig) string {
+	if config.Comments != "" {
+		if i := strings.LastIndex(markdown, "\n## Comments\n"); i >= 0 {
+			markdown = markdown[:i+1]
+		}
+	}
+
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+	fence := ""
+	// Before the body: front matter, the title and the table of contents
+	preamble := true
+	inFrontMatter := false
+	inDefinition := false
+	// dropped keeps the blank line after a removed line from doubling up
+	dropped := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			out = append(out, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			preamble = false
+			out = append(out, line)
+			continue
+		}
+
+		if i == 0 && (line == "---" || line == "+++") {
+			inFrontMatter = true
+			out = append(out, line)
+			continue
+		}
+		if inFrontMatter {
+			if line == "---" || line == "+++" {
+				inFrontMatter = false
+			}
+			out = append(out, line)
+			continue
+		}
+
+		if inDefinition {
+			// Replies of a comment continue the footnote indented
+			if trimmed == "" || strings.HasPrefix(line, "    ") {
+				continue
+			}
+			inDefinition = false
+		}
+		if config.Comments != "" && commentDefinition.MatchString(line) {
+			inDefinition = true
+			continue
+		}
+		if dropped && trimmed == "" && (len(out) == 0 || strings.TrimSpace(out[len(out)-1]) == "") {
+			continue
+		}
+		dropped = false
+
+		switch {
+		case config.TOC == "marker" && trimmed == "[TOC]",
+			config.TOC == "github" && preamble && tocLinkLine.MatchString(line),
+			config.SyncedBlockMode == "transclusion" && (obsidianBlockID.MatchString(trimmed) || obsidianEmbed.MatchString(trimmed)),
+			strings.HasPrefix(trimmed, "<!-- unsupported block:"),
+			strings.HasPrefix(trimmed, "[完整数据 (CSV)]("),
+			bitableRowsNote.MatchString(trimmed):
+			dropped = true
+			continue
+		}
+
+		if trimmed != "" && !(preamble && strings.HasPrefix(line, "# ")) {
+			preamble = false
+		}
+		switch config.HeadingAnchors {
+		case "attr":
+			line = headingAttrAnchor.ReplaceAllString(line, "$1")
+		case "html":
+			line = headingHTMLAnchor.ReplaceAllString(line, "$1")
+		}
+		if config.Comments != "" {
+			line = commentFootnote.ReplaceAllString(line, "")
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
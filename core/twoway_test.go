@@ -0,0 +1,104 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoWaySyncAction(t *testing.T) {
+	synced := core.SyncSides{
+		Synced:         true,
+		RevisionID:     3,
+		FileHash:       "h1",
+		RemoteRevision: 3,
+		LocalExists:    true,
+		LocalHash:      "h1",
+	}
+	tests := []struct {
+		name   string
+		modify func(s *core.SyncSides)
+		want   core.SyncAction
+	}{
+		{"unchanged", func(s *core.SyncSides) {}, core.SyncUpToDate},
+		{"pull", func(s *core.SyncSides) { s.RemoteRevision = 4 }, core.SyncPull},
+		{"pull missing file", func(s *core.SyncSides) { s.LocalExists, s.LocalHash = false, "" }, core.SyncUpToDate},
+		{"push", func(s *core.SyncSides) { s.LocalHash = "h2" }, core.SyncPush},
+		{"push only", func(s *core.SyncSides) { s.RemoteRevision, s.PushOnly = 4, true }, core.SyncSkipPull},
+		{"conflict", func(s *core.SyncSides) { s.LocalHash, s.RemoteRevision = "h2", 4 }, core.SyncConflict},
+		{"first sync", func(s *core.SyncSides) { *s = core.SyncSides{RemoteRevision: 4, LocalExists: true, LocalHash: "h2"} }, core.SyncConflict},
+		{"first pull", func(s *core.SyncSides) { *s = core.SyncSides{RemoteRevision: 4} }, core.SyncPull},
+		{"conflict pending", func(s *core.SyncSides) {
+			s.LocalHash, s.RemoteRevision, s.ConflictRevision, s.ConflictFileExists = "h2", 4, 4, true
+		}, core.SyncConflictPending},
+		{"resolved conflict", func(s *core.SyncSides) {
+			s.LocalHash, s.RemoteRevision, s.ConflictRevision = "h2", 4, 4
+		}, core.SyncPush},
+		{"resolved conflict, remote changed again", func(s *core.SyncSides) {
+			s.LocalHash, s.RemoteRevision, s.ConflictRevision = "h2", 5, 4
+		}, core.SyncConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sides := synced
+			tt.modify(&sides)
+			assert.Equal(t, tt.want, core.TwoWaySyncAction(sides))
+		})
+	}
+}
+
+func TestUnpushableBlocks(t *testing.T) {
+	blocks := []*lark.DocxBlock{
+		{BlockID: "doc", BlockType: lark.DocxBlockTypePage},
+		{BlockID: "t1", BlockType: lark.DocxBlockTypeText},
+		{BlockID: "board", BlockType: core.DocxBlockTypeBoard},
+		{BlockID: "tbl", BlockType: lark.DocxBlockTypeTable, Table: &lark.DocxBlockTable{
+			Property: &lark.DocxBlockTableProperty{MergeInfo: []*lark.DocxBlockTablePropertyMergeInfo{
+				{RowSpan: 1, ColSpan: 2},
+			}},
+		}},
+		{BlockID: "t2", BlockType: lark.DocxBlockTypeText},
+	}
+	exts := map[string]*core.DocxBlockExt{
+		"t2": {Comments: &core.DocxBlockComments{Elements: [][]string{nil, {"c1"}}}},
+	}
+	assert.Equal(t, []core.UnsupportedBlock{
+		{BlockType: core.DocxBlockTypeBoard, BlockID: "board"},
+		{BlockType: lark.DocxBlockTypeTable, BlockID: "tbl"},
+		{BlockType: lark.DocxBlockTypeText, BlockID: "t2"},
+	}, core.UnpushableBlocks(blocks, exts))
+	assert.Empty(t, core.UnpushableBlocks(blocks[:2], nil))
+}
+
+func TestStripExportSyntax(t *testing.T) {
+	exported := "---\ntitle: Doc\n---\n\n# Doc\n\n" +
+		"- [Intro](#intro)\n  - [Usage](#usage)\n\n" +
+		"## Intro {#intro}\n\nSee the note[^1].\n\n" +
+		"### Usage {#usage}\n\n![[Shared#^blk1]]\n\nshared\n\n^blk2\n\n" +
+		"| a |\n| - |\n| 1 |\n\n仅显示前 1 行，共 3 行，[完整数据 (CSV)](Doc/数据表.csv)\n\n" +
+		"<!-- unsupported block: type=43 id=x -->\n\n" +
+		"```md\n- [Intro](#intro)\n^blk3\n```\n" +
+		"\n[^1]: **Ann** (2024-01-02 10:00): fix\n\n    **Bob**: done\n" +
+		"\n## Comments\n\n> quote\n\n- **Ann**: hi\n"
+
+	config := core.NewConfig("", "").Output
+	config.TOC = "github"
+	config.HeadingAnchors = "attr"
+	config.SyncedBlockMode = "transclusion"
+	config.Comments = "footnotes"
+	stripped := core.StripExportSyntax(exported, config)
+
+	assert.Contains(t, stripped, "title: Doc\n---\n\n# Doc\n\n## Intro\n\nSee the note.\n")
+	assert.Contains(t, stripped, "### Usage\n")
+	assert.Contains(t, stripped, "shared\n")
+	assert.Contains(t, stripped, "```md\n- [Intro](#intro)\n^blk3\n```\n")
+	for _, gone := range []string{"![[", "^blk2", "仅显示前", ".csv", "unsupported", "[^1]", "Bob", "## Comments"} {
+		assert.NotContains(t, stripped, gone)
+	}
+
+	// Nothing is stripped that the configuration didn't produce
+	plain := "# Doc\n\n- [Intro](#intro)\n\n## Intro {#intro}\n\nA note[^1].\n\n[^1]: mine\n"
+	assert.Equal(t, plain, core.StripExportSyntax(plain, core.NewConfig("", "").Output))
+}
@@ -0,0 +1,450 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/chyroc/lark"
+	"gopkg.in/yaml.v3"
+)
+
+// UploadBlock is a docx block to create, together with the blocks to create
+// below it.
+type UploadBlock struct {
+	Block    *lark.DocxBlock
+	Children []*UploadBlock
+	// Cells holds the content of each cell of a table block, row by row
+	Cells [][]*UploadBlock
+	// Image is the source of an image block as written in the Markdown;
+	// ImageName and ImageData are filled by LoadUploadImages
+	Image     string
+	ImageName string
+	ImageData []byte
+}
+
+// mdStr2DocxCodeLang is the inverse of DocxCodeLang2MdStr. Languages sharing
+// a name resolve to the lowest code.
+var mdStr2DocxCodeLang = func() map[string]lark.DocxCodeLanguage {
+	m := make(map[string]lark.DocxCodeLanguage)
+	for lang, name := range DocxCodeLang2MdStr {
+		if old, ok := m[name]; !ok || lang < old {
+			m[name] = lang
+		}
+	}
+	return m
+}()
+
+// MarkdownToDocxBlocks converts Markdown into the docx blocks to create below
+// the page block of a document. The title comes from the front matter or,
+// with titleHeading, from a level-one heading the document starts with, which
+// is then not converted.
+func MarkdownToDocxBlocks(markdown string, titleHeading bool) (string, []*UploadBlock) {
+	engine := lute.New()
+	tree := parse.Parse("", []byte(markdown), engine.ParseOptions)
+
+	title := ""
+	first := tree.Root.FirstChild
+	if first != nil && first.Type == ast.NodeYamlFrontMatter {
+		title = frontMatterTitle(first)
+		first = first.Next
+	}
+	if titleHeading && first != nil && first.Type == ast.NodeHeading && first.HeadingLevel == 1 {
+		if title == "" {
+			title = strings.TrimSpace(first.Text())
+		}
+		first = first.Next
+	}
+
+	blocks := make([]*UploadBlock, 0)
+	for n := first; n != nil; n = n.Next {
+		blocks = append(blocks, convertMarkdownBlock(n)...)
+	}
+	return title, blocks
+}
+
+// LoadUploadImages fetches the picture of every image block with load, so
+// that missing files are reported before the document is touched.
+func LoadUploadImages(blocks []*UploadBlock, load func(src string) (string, []byte, error)) error {
+	for _, b := range blocks {
+		if b.Image != "" && b.ImageData == nil {
+			name, data, err := load(b.Image)
+			if err != nil {
+				return fmt.Errorf("failed to load image %s: %w", b.Image, err)
+			}
+			b.ImageName, b.ImageData = name, data
+		}
+		if err := LoadUploadImages(b.Children, load); err != nil {
+			return err
+		}
+		for _, cell := range b.Cells {
+			if err := LoadUploadImages(cell, load); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func frontMatterTitle(n *ast.Node) string {
+	content := n.ChildByType(ast.NodeYamlFrontMatterContent)
+	if content == nil {
+		return ""
+	}
+	var fields map[string]interface{}
+	if err := yaml.Unmarshal(content.Tokens, &fields); err != nil {
+		return ""
+	}
+	if title, ok := fields["title"].(string); ok {
+		return title
+	}
+	return ""
+}
+
+func newTextBlock(blockType lark.DocxBlockType, elements []*lark.DocxTextElement) *lark.DocxBlock {
+	b := &lark.DocxBlock{BlockType: blockType}
+	text := &lark.DocxBlockText{Elements: elements}
+	switch blockType {
+	case lark.DocxBlockTypeText:
+		b.Text = text
+	case lark.DocxBlockTypeHeading1:
+		b.Heading1 = text
+	case lark.DocxBlockTypeHeading2:
+		b.Heading2 = text
+	case lark.DocxBlockTypeHeading3:
+		b.Heading3 = text
+	case lark.DocxBlockTypeHeading4:
+		b.Heading4 = text
+	case lark.DocxBlockTypeHeading5:
+		b.Heading5 = text
+	case lark.DocxBlockTypeHeading6:
+		b.Heading6 = text
+	case lark.DocxBlockTypeBullet:
+		b.Bullet = text
+	case lark.DocxBlockTypeOrdered:
+		b.Ordered = text
+	case lark.DocxBlockTypeCode:
+		b.Code = text
+	case lark.DocxBlockTypeEquation:
+		b.Equation = text
+	case lark.DocxBlockTypeTodo:
+		b.Todo = text
+	}
+	return b
+}
+
+func textRun(content string, style lark.DocxTextElementStyle) *lark.DocxTextElement {
+	run := &lark.DocxTextElementTextRun{Content: content}
+	if style != (lark.DocxTextElementStyle{}) {
+		run.TextElementStyle = &style
+	}
+	return &lark.DocxTextElement{TextRun: run}
+}
+
+func convertMarkdownBlocks(n *ast.Node) []*UploadBlock {
+	blocks := make([]*UploadBlock, 0)
+	for c := n.FirstChild; c != nil; c = c.Next {
+		blocks = append(blocks, convertMarkdownBlock(c)...)
+	}
+	return blocks
+}
+
+func convertMarkdownBlock(n *ast.Node) []*UploadBlock {
+	switch n.Type {
+	case ast.NodeParagraph:
+		return convertParagraph(n)
+	case ast.NodeHeading:
+		return []*UploadBlock{{Block: newTextBlock(
+			lark.DocxBlockTypeHeading1+lark.DocxBlockType(n.HeadingLevel-1), inlineElements(n))}}
+	case ast.NodeThematicBreak:
+		return []*UploadBlock{{Block: &lark.DocxBlock{BlockType: lark.DocxBlockTypeDivider}}}
+	case ast.NodeBlockquote:
+		return []*UploadBlock{{
+			Block:    &lark.DocxBlock{BlockType: lark.DocxBlockTypeQuoteContainer, QuoteContainer: &lark.DocxBlocQuoteContainer{}},
+			Children: convertMarkdownBlocks(n),
+		}}
+	case ast.NodeList:
+		return convertList(n)
+	case ast.NodeCodeBlock:
+		return []*UploadBlock{convertCodeBlock(n)}
+	case ast.NodeMathBlock:
+		content := ""
+		if c := n.ChildByType(ast.NodeMathBlockContent); c != nil {
+			content = string(c.Tokens)
+		}
+		return []*UploadBlock{{Block: newTextBlock(lark.DocxBlockTypeText, []*lark.DocxTextElement{
+			{Equation: &lark.DocxTextElementEquation{Content: content}},
+		})}}
+	case ast.NodeTable:
+		return []*UploadBlock{convertTable(n)}
+	case ast.NodeHTMLBlock:
+		return []*UploadBlock{{Block: newTextBlock(lark.DocxBlockTypeText, []*lark.DocxTextElement{
+			textRun(strings.TrimSpace(string(n.Tokens)), lark.DocxTextElementStyle{}),
+		})}}
+	case ast.NodeLinkRefDefBlock, ast.NodeYamlFrontMatter, ast.NodeKramdownBlockIAL:
+		return nil
+	}
+	if n.IsContainerBlock() {
+		return convertMarkdownBlocks(n)
+	}
+	text := strings.TrimSpace(n.Text())
+	if text == "" {
+		return nil
+	}
+	return []*UploadBlock{{Block: newTextBlock(lark.DocxBlockTypeText, []*lark.DocxTextElement{
+		textRun(text, lark.DocxTextElementStyle{}),
+	})}}
+}
+
+// convertParagraph turns a paragraph into a text block, splitting it around
+// the images it contains since docx images are blocks of their own.
+func convertParagraph(n *ast.Node) []*UploadBlock {
+	blocks := make([]*UploadBlock, 0)
+	elements := make([]*lark.DocxTextElement, 0)
+	flush := func() {
+		elements = trimElements(elements)
+		if len(elements) > 0 {
+			blocks = append(blocks, &UploadBlock{Block: newTextBlock(lark.DocxBlockTypeText, elements)})
+		}
+		elements = make([]*lark.DocxTextElement, 0)
+	}
+	for c := n.FirstChild; c != nil; c = c.Next {
+		if c.Type == ast.NodeImage {
+			flush()
+			blocks = append(blocks, &UploadBlock{
+				Block: &lark.DocxBlock{BlockType: lark.DocxBlockTypeImage, Image: &lark.DocxBlockImage{}},
+				Image: linkDest(c),
+			})
+			continue
+		}
+		elements = appendInline(elements, c, lark.DocxTextElementStyle{})
+	}
+	flush()
+	return blocks
+}
+
+func convertList(n *ast.Node) []*UploadBlock {
+	blocks := make([]*UploadBlock, 0)
+	for item := n.FirstChild; item != nil; item = item.Next {
+		if item.Type != ast.NodeListItem {
+			continue
+		}
+		blockType := lark.DocxBlockTypeBullet
+		switch item.ListData.Typ {
+		case 1:
+			blockType = lark.DocxBlockTypeOrdered
+		case 3:
+			blockType = lark.DocxBlockTypeTodo
+		}
+		// The first paragraph is the text of the item, anything after it
+		// becomes its children
+		var elements []*lark.DocxTextElement
+		children := make([]*UploadBlock, 0)
+		for c := item.FirstChild; c != nil; c = c.Next {
+			if elements == nil && c.Type == ast.NodeParagraph {
+				elements = trimElements(inlineElements(c))
+				continue
+			}
+			children = append(children, convertMarkdownBlock(c)...)
+		}
+		b := newTextBlock(blockType, elements)
+		if blockType == lark.DocxBlockTypeTodo {
+			b.Todo.Style = &lark.DocxTextStyle{Done: item.ListData.Checked}
+		}
+		blocks = append(blocks, &UploadBlock{Block: b, Children: children})
+	}
+	return blocks
+}
+
+func convertCodeBlock(n *ast.Node) *UploadBlock {
+	code := ""
+	if c := n.ChildByType(ast.NodeCodeBlockCode); c != nil {
+		code = strings.TrimSuffix(string(c.Tokens), "\n")
+	}
+	lang := lark.DocxCodeLanguagePlainText
+	if info := n.ChildByType(ast.NodeCodeBlockFenceInfoMarker); info != nil {
+		name := strings.ToLower(strings.Fields(string(info.CodeBlockInfo) + " ")[0])
+		if l, ok := mdStr2DocxCodeLang[name]; ok {
+			lang = l
+		}
+	}
+	b := newTextBlock(lark.DocxBlockTypeCode, []*lark.DocxTextElement{textRun(code, lark.DocxTextElementStyle{})})
+	b.Code.Style = &lark.DocxTextStyle{Language: lang}
+	return &UploadBlock{Block: b}
+}
+
+func convertTable(n *ast.Node) *UploadBlock {
+	rows := make([]*ast.Node, 0)
+	ast.Walk(n, func(c *ast.Node, entering bool) ast.WalkStatus {
+		if entering && c.Type == ast.NodeTableRow {
+			rows = append(rows, c)
+			return ast.WalkSkipChildren
+		}
+		return ast.WalkContinue
+	})
+	columns := 0
+	for _, row := range rows {
+		if cells := len(row.ChildrenByType(ast.NodeTableCell)); cells > columns {
+			columns = cells
+		}
+	}
+	table := &UploadBlock{
+		Block: &lark.DocxBlock{BlockType: lark.DocxBlockTypeTable, Table: &lark.DocxBlockTable{
+			Property: &lark.DocxBlockTableProperty{RowSize: int64(len(rows)), ColumnSize: int64(columns)},
+		}},
+		Cells: make([][]*UploadBlock, 0, len(rows)*columns),
+	}
+	for _, row := range rows {
+		cells := row.ChildrenByType(ast.NodeTableCell)
+		for i := 0; i < columns; i++ {
+			content := make([]*UploadBlock, 0)
+			if i < len(cells) {
+				if elements := trimElements(inlineElements(cells[i])); len(elements) > 0 {
+					content = append(content, &UploadBlock{Block: newTextBlock(lark.DocxBlockTypeText, elements)})
+				}
+			}
+			table.Cells = append(table.Cells, content)
+		}
+	}
+	return table
+}
+
+func linkDest(n *ast.Node) string {
+	if dest := n.ChildByType(ast.NodeLinkDest); dest != nil {
+		return string(dest.Tokens)
+	}
+	return ""
+}
+
+func inlineElements(n *ast.Node) []*lark.DocxTextElement {
+	elements := make([]*lark.DocxTextElement, 0)
+	for c := n.FirstChild; c != nil; c = c.Next {
+		elements = appendInline(elements, c, lark.DocxTextElementStyle{})
+	}
+	return elements
+}
+
+// appendInline appends the text runs of an inline node with the styles of
+// the nodes around it, merging runs of the same style.
+func appendInline(elements []*lark.DocxTextElement, n *ast.Node, style lark.DocxTextElementStyle) []*lark.DocxTextElement {
+	appendText := func(content string) {
+		if content == "" {
+			return
+		}
+		if last := len(elements) - 1; last >= 0 && elements[last].TextRun != nil {
+			lastStyle := lark.DocxTextElementStyle{}
+			if s := elements[last].TextRun.TextElementStyle; s != nil {
+				lastStyle = *s
+			}
+			if sameTextStyle(lastStyle, style) {
+				elements[last].TextRun.Content += content
+				return
+			}
+		}
+		elements = append(elements, textRun(content, style))
+	}
+	children := func(style lark.DocxTextElementStyle) {
+		for c := n.FirstChild; c != nil; c = c.Next {
+			elements = appendInline(elements, c, style)
+		}
+	}
+
+	switch n.Type {
+	case ast.NodeStrong:
+		style.Bold = true
+		children(style)
+	case ast.NodeEmphasis:
+		style.Italic = true
+		children(style)
+	case ast.NodeStrikethrough:
+		style.Strikethrough = true
+		children(style)
+	case ast.NodeCodeSpan:
+		style.InlineCode = true
+		if c := n.ChildByType(ast.NodeCodeSpanContent); c != nil {
+			appendText(string(c.Tokens))
+		}
+	case ast.NodeLink:
+		style.Link = &lark.DocxTextElementStyleLink{URL: url.QueryEscape(linkDest(n))}
+		if text := n.ChildByType(ast.NodeLinkText); text != nil {
+			appendText(string(text.Tokens))
+		}
+	case ast.NodeImage:
+		// Images inside table cells or headings keep their alt text
+		if text := n.ChildByType(ast.NodeLinkText); text != nil {
+			appendText(string(text.Tokens))
+		}
+	case ast.NodeInlineMath:
+		if c := n.ChildByType(ast.NodeInlineMathContent); c != nil {
+			elements = append(elements, &lark.DocxTextElement{
+				Equation: &lark.DocxTextElementEquation{Content: string(c.Tokens)},
+			})
+		}
+	case ast.NodeSoftBreak:
+		appendText(" ")
+	case ast.NodeHardBreak:
+		appendText("\n")
+	case ast.NodeHTMLEntity:
+		appendText(html.UnescapeString(string(n.Tokens)))
+	case ast.NodeText, ast.NodeInlineHTML, ast.NodeBackslashContent:
+		appendText(string(n.Tokens))
+	default:
+		if n.IsMarker() || n.Type == ast.NodeBackslash && n.FirstChild == nil {
+			return elements
+		}
+		if n.FirstChild != nil {
+			children(style)
+		} else {
+			appendText(string(n.Tokens))
+		}
+	}
+	return elements
+}
+
+func sameTextStyle(a, b lark.DocxTextElementStyle) bool {
+	if (a.Link == nil) != (b.Link == nil) || a.Link != nil && a.Link.URL != b.Link.URL {
+		return false
+	}
+	a.Link, b.Link = nil, nil
+	return a == b
+}
+
+// trimElements trims the spaces around the text of a block.
+func trimElements(elements []*lark.DocxTextElement) []*lark.DocxTextElement {
+	if len(elements) > 0 && elements[0].TextRun != nil {
+		elements[0].TextRun.Content = strings.TrimLeft(elements[0].TextRun.Content, " \n")
+	}
+	if last := len(elements) - 1; last >= 0 && elements[last].TextRun != nil {
+		elements[last].TextRun.Content = strings.TrimRight(elements[last].TextRun.Content, " \n")
+	}
+	trimmed := make([]*lark.DocxTextElement, 0, len(elements))
+	for _, e := range elements {
+		if e.TextRun == nil || e.TextRun.Content != "" {
+			trimmed = append(trimmed, e)
+		}
+	}
+	return trimmed
+}
+
+// docxBlockJSON encodes a block for the block creation API. The SDK always
+// encodes the divider payload, which the API rejects on other blocks.
+func docxBlockJSON(b *lark.DocxBlock) (json.RawMessage, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if b.BlockType == lark.DocxBlockTypeDivider {
+		return data, nil
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "divider")
+	return json.Marshal(fields)
+}
@@ -0,0 +1,217 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Wsine/feishu2md/core"
+	"github.com/chyroc/lark"
+	"github.com/stretchr/testify/assert"
+)
+
+const uploadMarkdown = `---
+title: Release notes
+---
+
+Hello **bold** and [docs](https://example.com/a?b=1)
+
+## Steps
+
+1. build
+   - with ` + "`make`" + `
+2. ship
+
+- [x] done
+
+> quoted
+
+` + "```go\nfmt.Println()\n```" + `
+
+---
+
+| a | b |
+|---|---|
+| 1 |   |
+
+![chart](img/chart.png)
+`
+
+func TestMarkdownToDocxBlocks(t *testing.T) {
+	title, blocks := core.MarkdownToDocxBlocks(uploadMarkdown, true)
+	assert.Equal(t, "Release notes", title)
+
+	types := make([]lark.DocxBlockType, 0)
+	for _, b := range blocks {
+		types = append(types, b.Block.BlockType)
+	}
+	assert.Equal(t, []lark.DocxBlockType{
+		lark.DocxBlockTypeText, lark.DocxBlockTypeHeading2,
+		lark.DocxBlockTypeOrdered, lark.DocxBlockTypeOrdered, lark.DocxBlockTypeTodo,
+		lark.DocxBlockTypeQuoteContainer, lark.DocxBlockTypeCode, lark.DocxBlockTypeDivider,
+		lark.DocxBlockTypeTable, lark.DocxBlockTypeImage,
+	}, types)
+
+	text := blocks[0].Block.Text.Elements
+	assert.Len(t, text, 4)
+	assert.Equal(t, "bold", text[1].TextRun.Content)
+	assert.True(t, text[1].TextRun.TextElementStyle.Bold)
+	assert.Equal(t, "https%3A%2F%2Fexample.com%2Fa%3Fb%3D1", text[3].TextRun.TextElementStyle.Link.URL)
+
+	nested := blocks[2].Children
+	assert.Len(t, nested, 1)
+	assert.Equal(t, lark.DocxBlockTypeBullet, nested[0].Block.BlockType)
+	assert.True(t, nested[0].Block.Bullet.Elements[1].TextRun.TextElementStyle.InlineCode)
+	assert.True(t, blocks[4].Block.Todo.Style.Done)
+	assert.Equal(t, lark.DocxBlockTypeText, blocks[5].Children[0].Block.BlockType)
+	assert.Equal(t, lark.DocxCodeLanguageGo, blocks[6].Block.Code.Style.Language)
+
+	table := blocks[8]
+	assert.Equal(t, int64(2), table.Block.Table.Property.RowSize)
+	assert.Equal(t, int64(2), table.Block.Table.Property.ColumnSize)
+	assert.Len(t, table.Cells, 4)
+	assert.Empty(t, table.Cells[3])
+	assert.Equal(t, "img/chart.png", blocks[9].Image)
+
+	title, blocks = core.MarkdownToDocxBlocks("# Heading title\n\nBody\n", true)
+	assert.Equal(t, "Heading title", title)
+	assert.Len(t, blocks, 1)
+	title, blocks = core.MarkdownToDocxBlocks("# Heading title\n\nBody\n", false)
+	assert.Equal(t, "", title)
+	assert.Len(t, blocks, 2)
+}
+
+// fakeDocxServer records the docx API calls of an upload.
+type fakeDocxServer struct {
+	mu      sync.Mutex
+	nextID  int
+	created map[string][]lark.DocxBlockType
+	deleted []string
+	updated []string
+	// children of the page block, starting with two old blocks
+	children []string
+	// failParent makes creating blocks below it fail
+	failParent string
+}
+
+func (s *fakeDocxServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply := func(data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": data})
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/open-apis/docx/v1/documents/doc1/blocks/")
+	switch {
+	case r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal":
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+	case r.URL.Path == "/open-apis/docx/v1/documents" && r.Method == http.MethodPost:
+		reply(map[string]interface{}{"document": map[string]interface{}{"document_id": "doc1", "revision_id": 1}})
+	case r.URL.Path == "/open-apis/drive/v1/medias/upload_all":
+		reply(map[string]interface{}{"file_token": "img-token"})
+	case strings.HasSuffix(path, "/children/batch_delete"):
+		var req map[string]int64
+		json.NewDecoder(r.Body).Decode(&req)
+		parent := strings.TrimSuffix(path, "/children/batch_delete")
+		s.deleted = append(s.deleted, fmt.Sprintf("%s[%d:%d]", parent, req["start_index"], req["end_index"]))
+		if parent == "doc1" {
+			s.children = append(s.children[:req["start_index"]:req["start_index"]], s.children[req["end_index"]:]...)
+		}
+		reply(map[string]interface{}{})
+	case strings.HasSuffix(path, "/children"):
+		var req struct {
+			Children []*lark.DocxBlock `json:"children"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		parent := strings.TrimSuffix(path, "/children")
+		if parent == s.failParent {
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 1770001, "msg": "invalid param"})
+			return
+		}
+		for _, b := range req.Children {
+			s.nextID++
+			b.BlockID = fmt.Sprintf("b%d", s.nextID)
+			s.created[parent] = append(s.created[parent], b.BlockType)
+			if parent == "doc1" {
+				s.children = append(s.children, b.BlockID)
+			}
+			if b.Table != nil {
+				size := b.Table.Property.RowSize * b.Table.Property.ColumnSize
+				for i := int64(0); i < size; i++ {
+					b.Table.Cells = append(b.Table.Cells, fmt.Sprintf("%s-c%d", b.BlockID, i))
+				}
+			}
+		}
+		reply(map[string]interface{}{"children": req.Children})
+	case r.Method == http.MethodGet && path == "doc1":
+		reply(map[string]interface{}{"block": map[string]interface{}{
+			"block_id": "doc1", "block_type": 1, "children": s.children,
+		}})
+	case r.Method == http.MethodPatch:
+		body := new(strings.Builder)
+		fmt.Fprint(body, path, " ")
+		var req map[string]json.RawMessage
+		json.NewDecoder(r.Body).Decode(&req)
+		for k := range req {
+			fmt.Fprint(body, k)
+		}
+		s.updated = append(s.updated, body.String())
+		reply(map[string]interface{}{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestUploadDocx(t *testing.T) {
+	fake := &fakeDocxServer{created: make(map[string][]lark.DocxBlockType), children: []string{"old1", "old2"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := core.NewClientWithBaseURL("app", "secret", server.URL)
+	ctx := context.Background()
+
+	title, blocks := core.MarkdownToDocxBlocks(uploadMarkdown, true)
+	err := core.LoadUploadImages(blocks, func(src string) (string, []byte, error) {
+		return "chart.png", []byte("png"), nil
+	})
+	assert.NoError(t, err)
+
+	documentID, err := client.CreateDocx(ctx, "folder", title)
+	assert.NoError(t, err)
+	assert.Equal(t, "doc1", documentID)
+	err = client.ReplaceDocxContent(ctx, documentID, title, blocks)
+	assert.NoError(t, err)
+
+	assert.Len(t, fake.created["doc1"], 10)
+	// The nested bullet, the quoted text and the filled table cells
+	assert.Equal(t, []lark.DocxBlockType{lark.DocxBlockTypeBullet}, fake.created["b3"])
+	assert.Equal(t, []lark.DocxBlockType{lark.DocxBlockTypeText}, fake.created["b6"])
+	assert.Equal(t, []lark.DocxBlockType{lark.DocxBlockTypeText}, fake.created["b9-c0"])
+	assert.Empty(t, fake.created["b9-c3"])
+	// The old blocks are deleted once the new ones exist, the title set last
+	assert.Equal(t, []string{"b9-c0[0:1]", "b9-c1[0:1]", "b9-c2[0:1]", "doc1[0:2]"}, fake.deleted)
+	assert.Equal(t, []string{"b10 replace_image", "doc1 update_text_elements"}, fake.updated)
+	assert.Len(t, fake.children, 10)
+}
+
+func TestReplaceDocxContentRollback(t *testing.T) {
+	fake := &fakeDocxServer{created: make(map[string][]lark.DocxBlockType), children: []string{"old1", "old2"}}
+	// Filling the table cells fails after the top-level blocks were created
+	fake.failParent = "b9-c0"
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := core.NewClientWithBaseURL("app", "secret", server.URL)
+
+	title, blocks := core.MarkdownToDocxBlocks(uploadMarkdown, true)
+	core.LoadUploadImages(blocks, func(src string) (string, []byte, error) {
+		return "chart.png", []byte("png"), nil
+	})
+	err := client.ReplaceDocxContent(context.Background(), "doc1", title, blocks)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"doc1[2:12]"}, fake.deleted)
+	assert.Equal(t, []string{"old1", "old2"}, fake.children)
+	assert.Empty(t, fake.updated)
+}
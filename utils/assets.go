@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var assetHashRegexp = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// ContentAddressedPath returns the path of data in a shared assets directory,
+// named by its SHA-256 and sharded by the first two hex digits.
+func ContentAddressedPath(dir, ext string, data []byte) string {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(dir, hash[:2], hash+ext)
+}
+
+// WriteContentAddressed stores data under its content address and returns the
+// path. Identical content already on disk is left untouched.
+func WriteContentAddressed(dir, ext string, data []byte) (string, error) {
+	path := ContentAddressedPath(dir, ext, data)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temporary file first so concurrent writers never expose a
+	// partially written asset.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// CollectUnreferencedAssets removes files from assetsDir whose content hash is
+// not mentioned by any markdown file under mdRoot, and returns their paths.
+func CollectUnreferencedAssets(assetsDir, mdRoot string) ([]string, error) {
+	referenced := make(map[string]bool)
+	err := filepath.WalkDir(mdRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, hash := range assetHashRegexp.FindAllString(string(data), -1) {
+			referenced[hash] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	err = filepath.WalkDir(assetsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		hash := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		if referenced[hash] {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	return removed, err
+}
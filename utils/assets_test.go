@@ -0,0 +1,53 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Wsine/feishu2md/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteContentAddressed(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := utils.WriteContentAddressed(dir, ".png", []byte("logo"))
+	assert.NoError(t, err)
+	second, err := utils.WriteContentAddressed(dir, ".png", []byte("logo"))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	other, err := utils.WriteContentAddressed(dir, ".png", []byte("banner"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, other)
+
+	name := filepath.Base(first)
+	assert.Equal(t, filepath.Join(dir, name[:2], name), first)
+	data, err := os.ReadFile(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "logo", string(data))
+}
+
+func TestCollectUnreferencedAssets(t *testing.T) {
+	root := t.TempDir()
+	assetsDir := filepath.Join(root, "assets")
+
+	used, err := utils.WriteContentAddressed(assetsDir, ".png", []byte("used"))
+	assert.NoError(t, err)
+	unused, err := utils.WriteContentAddressed(assetsDir, ".png", []byte("unused"))
+	assert.NoError(t, err)
+
+	rel, _ := filepath.Rel(filepath.Join(root, "group"), used)
+	md := "![](" + filepath.ToSlash(rel) + ")\n"
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "group"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "group", "doc.md"), []byte(md), 0o644))
+
+	removed, err := utils.CollectUnreferencedAssets(assetsDir, root)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{unused}, removed)
+	assert.FileExists(t, used)
+	assert.NoFileExists(t, unused)
+	assert.True(t, strings.HasPrefix(md, "![](../assets/"))
+}
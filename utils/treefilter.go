@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// TreeFilter selects the nodes of a wiki or folder tree. A node is addressed by
+// the titles from the start of the walk down to itself.
+//
+// A pattern without "/" is matched against the title of the node and of each
+// of its ancestors; a pattern with "/" is matched segment by segment against
+// the leading titles of the path. Either way a match selects the whole subtree,
+// so "Engineering" or "Engineering/Back*" export a section of the tree.
+type TreeFilter struct {
+	Include []string
+	Exclude []string
+	// MaxDepth limits the walk to nodes at most MaxDepth levels below the
+	// start, 0 for unlimited.
+	MaxDepth int
+}
+
+// Excluded reports whether the node and its subtree are excluded.
+func (f *TreeFilter) Excluded(titles []string) bool {
+	if f == nil {
+		return false
+	}
+	for _, pattern := range f.Exclude {
+		if matchTreePattern(pattern, titles) {
+			return true
+		}
+	}
+	return false
+}
+
+// Included reports whether the node itself is selected.
+func (f *TreeFilter) Included(titles []string) bool {
+	if f == nil || len(f.Include) == 0 {
+		return !f.Excluded(titles)
+	}
+	if f.Excluded(titles) {
+		return false
+	}
+	for _, pattern := range f.Include {
+		if matchTreePattern(pattern, titles) {
+			return true
+		}
+	}
+	return false
+}
+
+// Descend reports whether the children of the node at the given depth may
+// contain selected nodes, so that excluded sections are never listed.
+func (f *TreeFilter) Descend(titles []string, depth int) bool {
+	if f == nil {
+		return true
+	}
+	if (f.MaxDepth > 0 && depth >= f.MaxDepth) || f.Excluded(titles) {
+		return false
+	}
+	if len(f.Include) == 0 || f.Included(titles) {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if !strings.Contains(pattern, "/") {
+			// A title may appear anywhere below
+			return true
+		}
+		segments := strings.Split(strings.Trim(pattern, "/"), "/")
+		if len(segments) > len(titles) && matchSegments(segments[:len(titles)], titles) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTreePattern(pattern string, titles []string) bool {
+	if !strings.Contains(pattern, "/") {
+		for _, title := range titles {
+			if ok, _ := path.Match(pattern, title); ok {
+				return true
+			}
+		}
+		return false
+	}
+	segments := strings.Split(strings.Trim(pattern, "/"), "/")
+	return len(segments) <= len(titles) && matchSegments(segments, titles[:len(segments)])
+}
+
+func matchSegments(patterns, titles []string) bool {
+	for i, pattern := range patterns {
+		if ok, _ := path.Match(pattern, titles[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
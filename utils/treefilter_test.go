@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeFilter(t *testing.T) {
+	f := &TreeFilter{
+		Include: []string{"Engineering/Back*"},
+		Exclude: []string{"Archive*"},
+	}
+	assert.True(t, f.Included([]string{"Engineering", "Backend"}))
+	assert.True(t, f.Included([]string{"Engineering", "Backend", "API"}))
+	assert.False(t, f.Included([]string{"Engineering"}))
+	assert.False(t, f.Included([]string{"Engineering", "Backend", "Archive 2020"}))
+	assert.True(t, f.Descend([]string{"Engineering"}, 1))
+	assert.False(t, f.Descend([]string{"Sales"}, 1))
+	assert.False(t, f.Descend([]string{"Engineering", "Backend", "Archive"}, 3))
+
+	f = &TreeFilter{Include: []string{"*API*"}, MaxDepth: 2}
+	assert.True(t, f.Included([]string{"Engineering", "Public API"}))
+	assert.True(t, f.Descend([]string{"Sales"}, 1))
+	assert.False(t, f.Descend([]string{"Sales", "Leads"}, 2))
+
+	var none *TreeFilter
+	assert.True(t, none.Included([]string{"any"}))
+	assert.True(t, none.Descend([]string{"any"}, 10))
+}
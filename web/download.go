@@ -38,6 +38,10 @@ func downloadHandler(c *gin.Context) {
 	client := core.NewClient(
 		config.Feishu.AppId, config.Feishu.AppSecret,
 	)
+	// image_mode=data_uri or remote returns a single markdown file
+	if imageMode := c.Query("image_mode"); imageMode != "" {
+		config.Output.ImageMode = imageMode
+	}
 
 	// Process the download
 	parser := core.NewParser(config.Output)
@@ -59,24 +63,76 @@ func downloadHandler(c *gin.Context) {
 		return
 	}
 
-	docx, blocks, err := client.GetDocxContent(ctx, docToken)
+	docx, blocks, blockExts, err := client.GetDocxContentExt(ctx, docToken)
 	if err != nil {
 		c.String(http.StatusInternalServerError, "Internal error: client.GetDocxContent")
 		log.Panicf("error: %s", err)
 		return
 	}
+	parser.SetBlockExts(blockExts)
+	parser.SetSourceURL(feishu_docx_url)
 	markdown = parser.ParseDocxContent(docx, blocks)
 
 	zipBuffer := new(bytes.Buffer)
 	writer := zip.NewWriter(zipBuffer)
+	zipped := 0
 	for _, imgToken := range parser.ImgTokens {
+		if config.Output.ImageMode == "remote" {
+			markdown = strings.Replace(markdown, imgToken, parser.RemoteMediaURL(imgToken), 1)
+			continue
+		}
 		localLink, rawImage, err := client.DownloadImageRaw(ctx, imgToken, config.Output.ImageDir)
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Internal error: client.DownloadImageRaw")
 			log.Panicf("error: %s", err)
 			return
 		}
+		localLink, rawImage, err = core.ProcessImage(localLink, rawImage, config.Output)
+		if err != nil {
+			log.Printf("warning: process image %s: %s", imgToken, err)
+		}
+		if config.Output.ImageMode == "data_uri" && len(rawImage) <= config.Output.DataURIMaxBytes {
+			markdown = strings.Replace(markdown, imgToken, core.DataURI(localLink, rawImage), 1)
+			continue
+		}
 		markdown = strings.Replace(markdown, imgToken, localLink, 1)
+		zipped++
+		f, err := writer.Create(localLink)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")
+			log.Panicf("error: %s", err)
+			return
+		}
+		_, err = f.Write(rawImage)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create.Write")
+			log.Panicf("error: %s", err)
+			return
+		}
+	}
+
+	for _, boardToken := range parser.BoardTokens {
+		if config.Output.ImageMode == "remote" {
+			markdown = strings.Replace(markdown,
+				fmt.Sprintf("![](%s)", boardToken), parser.SourceLink("画板", ""), 1)
+			continue
+		}
+		localLink, rawImage, err := client.DownloadBoardImageRaw(ctx, boardToken, config.Output.ImageDir)
+		if err != nil {
+			markdown = strings.Replace(markdown,
+				fmt.Sprintf("![](%s)", boardToken), parser.SourceLink("画板", ""), 1)
+			continue
+		}
+		localLink, rawImage, err = core.ProcessImage(localLink, rawImage, config.Output)
+		if err != nil {
+			log.Printf("warning: process image %s: %s", boardToken, err)
+		}
+		if config.Output.ImageMode == "data_uri" && len(rawImage) <= config.Output.DataURIMaxBytes {
+			markdown = strings.Replace(markdown, boardToken, core.DataURI(localLink, rawImage), 1)
+			continue
+		}
+		markdown = strings.Replace(markdown, boardToken, localLink, 1)
+		zipped++
 		f, err := writer.Create(localLink)
 		if err != nil {
 			c.String(http.StatusInternalServerError, "Internal error: zipWriter.Create")
@@ -97,7 +153,7 @@ func downloadHandler(c *gin.Context) {
 	result := engine.FormatStr("md", markdown)
 
 	// Set response
-	if len(parser.ImgTokens) > 0 {
+	if zipped > 0 {
 		mdName := fmt.Sprintf("%s.md", docToken)
 		f, err := writer.Create(mdName)
 		if err != nil {